@@ -0,0 +1,209 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHeartbeatInterval = 30 * time.Second
+	DefaultHeartbeatTimeout  = 10 * time.Second
+)
+
+var (
+	DefaultPingPayload = []byte("ping")
+	DefaultPongPayload = []byte("pong")
+
+	ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+)
+
+type HeartbeatOptions struct {
+	// Interval is the time between two pings, DefaultHeartbeatInterval is used when <= 0.
+	Interval time.Duration
+	// Timeout is how long to wait for a pong after a ping, DefaultHeartbeatTimeout is used when <= 0.
+	Timeout time.Duration
+	// PingPayload and PongPayload identify heartbeat frames; they are never delivered to OnMessage.
+	// Incoming pings are answered with a pong so both peers can run heartbeats.
+	PingPayload []byte
+	PongPayload []byte
+}
+
+type heartbeatConnection struct {
+	Connection
+	ctx        context.Context
+	cancelFunc func()
+	opts       HeartbeatOptions
+	mutex      *sync.Mutex
+	pongCh     chan struct{}
+	timedOut   bool
+	closed     bool
+	onMessage  func([]byte)
+	onClose    func(error)
+}
+
+// NewHeartbeatConnection wraps conn with a heartbeat: a ping is sent every interval and the connection is
+// closed with ErrHeartbeatTimeout, moving to StateDisconnected, when no pong arrives within the timeout.
+func NewHeartbeatConnection(conn Connection, opts *HeartbeatOptions) Connection {
+	if opts == nil {
+		opts = &HeartbeatOptions{}
+	}
+	cfg := *opts
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultHeartbeatInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultHeartbeatTimeout
+	}
+	if len(cfg.PingPayload) == 0 {
+		cfg.PingPayload = DefaultPingPayload
+	}
+	if len(cfg.PongPayload) == 0 {
+		cfg.PongPayload = DefaultPongPayload
+	}
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	c := &heartbeatConnection{
+		Connection: conn,
+		ctx:        ctx,
+		cancelFunc: cancelFunc,
+		opts:       cfg,
+		mutex:      new(sync.Mutex),
+		pongCh:     make(chan struct{}, 1),
+	}
+	conn.OnMessage(c.handleMessage)
+	conn.OnClose(c.handleClose)
+	go c.heartbeatRoutine()
+	return c
+}
+
+// handleHeartbeatFrame consumes ping and pong frames and reports whether data was one of them.
+func (c *heartbeatConnection) handleHeartbeatFrame(data []byte) bool {
+	if bytes.Equal(data, c.opts.PongPayload) {
+		select {
+		case c.pongCh <- struct{}{}:
+		default:
+		}
+		return true
+	}
+	if bytes.Equal(data, c.opts.PingPayload) {
+		c.Connection.Write(c.opts.PongPayload)
+		return true
+	}
+	return false
+}
+
+func (c *heartbeatConnection) handleMessage(data []byte) {
+	if c.handleHeartbeatFrame(data) {
+		return
+	}
+	c.mutex.Lock()
+	onMessage := c.onMessage
+	c.mutex.Unlock()
+	if onMessage != nil {
+		onMessage(data)
+	}
+}
+
+func (c *heartbeatConnection) handleClose(err error) {
+	c.cancelFunc()
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.closed = true
+	if c.timedOut {
+		err = ErrHeartbeatTimeout
+	}
+	onClose := c.onClose
+	c.mutex.Unlock()
+	if onClose != nil {
+		onClose(err)
+	}
+}
+
+func (c *heartbeatConnection) heartbeatRoutine() {
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+		// drop pongs that arrived late for previous pings
+		select {
+		case <-c.pongCh:
+		default:
+		}
+		if err := c.Connection.Write(c.opts.PingPayload); err != nil {
+			c.timeout()
+			return
+		}
+		deadline := time.NewTimer(c.opts.Timeout)
+		select {
+		case <-c.pongCh:
+			deadline.Stop()
+		case <-deadline.C:
+			c.timeout()
+			return
+		case <-c.ctx.Done():
+			deadline.Stop()
+			return
+		}
+	}
+}
+
+func (c *heartbeatConnection) timeout() {
+	c.mutex.Lock()
+	c.timedOut = true
+	c.mutex.Unlock()
+	c.Connection.Close()
+	// make sure listeners are notified even if the underlying connection does not fire OnClose on Close
+	c.handleClose(ErrHeartbeatTimeout)
+}
+
+func (c *heartbeatConnection) Read() ([]byte, error) {
+	for {
+		data, err := c.Connection.Read()
+		if err != nil || !c.handleHeartbeatFrame(data) {
+			return data, err
+		}
+	}
+}
+
+func (c *heartbeatConnection) OnMessage(cb func([]byte)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onMessage = cb
+}
+
+func (c *heartbeatConnection) OnClose(cb func(error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onClose = cb
+}
+
+func (c *heartbeatConnection) State() ConnectionState {
+	c.mutex.Lock()
+	timedOut := c.timedOut
+	c.mutex.Unlock()
+	if timedOut {
+		return StateDisconnected
+	}
+	return c.Connection.State()
+}
+
+func (c *heartbeatConnection) IsLive() bool {
+	c.mutex.Lock()
+	timedOut := c.timedOut
+	c.mutex.Unlock()
+	return !timedOut && c.Connection.IsLive()
+}
+
+func (c *heartbeatConnection) Close() error {
+	c.cancelFunc()
+	return c.Connection.Close()
+}
@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatConnectionKeepsLiveConnectionOpen(t *testing.T) {
+	conn := &fakeConnection{live: true}
+	conn.onWrite = func(data []byte) {
+		if bytes.Equal(data, DefaultPingPayload) {
+			go conn.receive(DefaultPongPayload)
+		}
+	}
+	c := NewHeartbeatConnection(conn, &HeartbeatOptions{Interval: 10 * time.Millisecond, Timeout: 20 * time.Millisecond})
+	defer c.Close()
+
+	received := make(chan []byte, 1)
+	c.OnMessage(func(data []byte) { received <- data })
+
+	time.Sleep(100 * time.Millisecond)
+	if !c.IsLive() || c.State() == StateDisconnected {
+		t.Fatal("expected connection to stay live while pongs arrive")
+	}
+	conn.receive([]byte("payload"))
+	select {
+	case data := <-received:
+		if string(data) != "payload" {
+			t.Errorf("unexpected message %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected regular message to be forwarded")
+	}
+}
+
+func TestHeartbeatConnectionTimesOut(t *testing.T) {
+	conn := &fakeConnection{live: true}
+	c := NewHeartbeatConnection(conn, &HeartbeatOptions{
+		Interval:    10 * time.Millisecond,
+		Timeout:     20 * time.Millisecond,
+		PingPayload: []byte("hb?"),
+		PongPayload: []byte("hb!"),
+	})
+	closed := make(chan error, 2)
+	c.OnClose(func(err error) { closed <- err })
+
+	select {
+	case err := <-closed:
+		if !errors.Is(err, ErrHeartbeatTimeout) {
+			t.Errorf("expected ErrHeartbeatTimeout, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnClose after missing pong")
+	}
+	if c.State() != StateDisconnected {
+		t.Errorf("expected StateDisconnected, got %d", c.State())
+	}
+	if writes := conn.writes(); len(writes) == 0 || string(writes[0]) != "hb?" {
+		t.Errorf("expected custom ping payload to be sent, got %v", writes)
+	}
+	select {
+	case err := <-closed:
+		t.Errorf("expected OnClose to fire once, got a second call with %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHeartbeatConnectionAnswersPings(t *testing.T) {
+	conn := &fakeConnection{live: true}
+	c := NewHeartbeatConnection(conn, &HeartbeatOptions{Interval: time.Hour})
+	defer c.Close()
+	c.OnMessage(func(data []byte) { t.Errorf("heartbeat frame %q must not be forwarded", data) })
+
+	conn.receive(DefaultPingPayload)
+	if writes := conn.writes(); len(writes) != 1 || !bytes.Equal(writes[0], DefaultPongPayload) {
+		t.Errorf("expected pong reply, got %v", writes)
+	}
+}
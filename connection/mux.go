@@ -0,0 +1,535 @@
+package connection
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMuxStreamWindow   = 64
+	DefaultMuxAcceptBacklog  = 64
+	DefaultMuxRequestTimeout = 30 * time.Second
+)
+
+const (
+	muxFrameOpen   byte = 1
+	muxFrameData   byte = 2
+	muxFrameClose  byte = 3
+	muxFrameWindow byte = 4
+
+	// frame layout: [type(1)][stream id(4)][payload]
+	muxFrameHeaderSize = 5
+)
+
+var (
+	ErrMuxClosed         = errors.New("mux is closed")
+	ErrMuxWindowExceeded = errors.New("stream receive window exceeded")
+	ErrMuxProtocol       = errors.New("mux protocol error")
+)
+
+type MuxOptions struct {
+	// Server decides the stream id space, the two peers of a mux must use different values.
+	Server bool
+	// StreamWindow is the number of unread messages a stream buffers before its peer's writes block,
+	// DefaultMuxStreamWindow is used when <= 0.
+	StreamWindow int
+	// AcceptBacklog is the number of opened streams waiting for AcceptStream, DefaultMuxAcceptBacklog is used when <= 0.
+	AcceptBacklog int
+	// RequestTimeout bounds Request calls whose context carries no deadline, DefaultMuxRequestTimeout is used when <= 0.
+	RequestTimeout time.Duration
+}
+
+// Mux runs many logical streams over a single Connection. Streams are Connections themselves.
+type Mux interface {
+	OpenStream() (Connection, error)
+	// AcceptStream blocks until the peer opens a stream or the mux is closed.
+	AcceptStream() (Connection, error)
+	// Request opens a stream, writes data and waits for the first message written back by the peer.
+	Request(ctx context.Context, data []byte) ([]byte, error)
+	// Serve accepts streams and answers the first message of each stream with the handler's result until the
+	// mux is closed. A handler error closes the stream with the error, which is returned to the requester.
+	Serve(handler func(request []byte) ([]byte, error)) error
+	NumStreams() int
+	Close() error
+}
+
+type mux struct {
+	conn       Connection
+	opts       MuxOptions
+	mutex      *sync.Mutex
+	writeMutex *sync.Mutex
+	streams    map[uint32]*muxStream
+	nextID     uint32
+	acceptCh   chan *muxStream
+	done       chan struct{}
+	closeOnce  *sync.Once
+}
+
+// NewMux creates a mux over conn. The mux takes over conn's OnMessage/OnClose callbacks and runs its ReadLoop.
+func NewMux(conn Connection, opts *MuxOptions) Mux {
+	if opts == nil {
+		opts = &MuxOptions{}
+	}
+	cfg := *opts
+	if cfg.StreamWindow <= 0 {
+		cfg.StreamWindow = DefaultMuxStreamWindow
+	}
+	if cfg.AcceptBacklog <= 0 {
+		cfg.AcceptBacklog = DefaultMuxAcceptBacklog
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultMuxRequestTimeout
+	}
+	m := &mux{
+		conn:       conn,
+		opts:       cfg,
+		mutex:      new(sync.Mutex),
+		writeMutex: new(sync.Mutex),
+		streams:    make(map[uint32]*muxStream),
+		nextID:     1,
+		acceptCh:   make(chan *muxStream, cfg.AcceptBacklog),
+		done:       make(chan struct{}),
+		closeOnce:  new(sync.Once),
+	}
+	if cfg.Server {
+		m.nextID = 2
+	}
+	conn.OnMessage(m.handleFrame)
+	conn.OnClose(func(err error) {
+		if err == nil {
+			err = ErrConnectionClosed
+		}
+		m.closeWith(err)
+	})
+	go conn.ReadLoop()
+	return m
+}
+
+func encodeMuxFrame(frameType byte, streamID uint32, payload []byte) []byte {
+	frame := make([]byte, muxFrameHeaderSize+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint32(frame[1:muxFrameHeaderSize], streamID)
+	copy(frame[muxFrameHeaderSize:], payload)
+	return frame
+}
+
+func (m *mux) writeFrame(frameType byte, streamID uint32, payload []byte) error {
+	select {
+	case <-m.done:
+		return ErrMuxClosed
+	default:
+	}
+	m.writeMutex.Lock()
+	defer m.writeMutex.Unlock()
+	return m.conn.Write(encodeMuxFrame(frameType, streamID, payload))
+}
+
+func (m *mux) handleFrame(frame []byte) {
+	if len(frame) < muxFrameHeaderSize {
+		return
+	}
+	frameType := frame[0]
+	streamID := binary.BigEndian.Uint32(frame[1:muxFrameHeaderSize])
+	payload := frame[muxFrameHeaderSize:]
+
+	if frameType == muxFrameOpen {
+		m.handleOpen(streamID)
+		return
+	}
+	m.mutex.Lock()
+	stream := m.streams[streamID]
+	m.mutex.Unlock()
+	if stream == nil {
+		// frames for streams closed locally are discarded
+		return
+	}
+	switch frameType {
+	case muxFrameData:
+		stream.receive(payload)
+	case muxFrameWindow:
+		if len(payload) == 4 {
+			stream.grantCredits(int(binary.BigEndian.Uint32(payload)))
+		}
+	case muxFrameClose:
+		var reason error
+		if len(payload) > 0 {
+			reason = errors.New(string(payload))
+		}
+		stream.closeRemotely(reason)
+	}
+}
+
+func (m *mux) handleOpen(streamID uint32) {
+	// the peer opens odd ids when it is the client and even ids when it is the server, id 0 is never used
+	if streamID == 0 || (streamID%2 == 1) == !m.opts.Server {
+		m.writeFrame(muxFrameClose, streamID, []byte(fmt.Sprintf("%v: stream id %d has the wrong parity", ErrMuxProtocol, streamID)))
+		return
+	}
+	stream := newMuxStream(m, streamID)
+	m.mutex.Lock()
+	if _, exists := m.streams[streamID]; exists {
+		m.mutex.Unlock()
+		return
+	}
+	m.streams[streamID] = stream
+	m.mutex.Unlock()
+	select {
+	case m.acceptCh <- stream:
+	default:
+		stream.closeWith(errors.New("accept backlog is full"), true)
+	}
+}
+
+func (m *mux) removeStream(streamID uint32) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.streams, streamID)
+}
+
+func (m *mux) OpenStream() (Connection, error) {
+	m.mutex.Lock()
+	select {
+	case <-m.done:
+		m.mutex.Unlock()
+		return nil, ErrMuxClosed
+	default:
+	}
+	streamID := m.nextID
+	m.nextID += 2
+	stream := newMuxStream(m, streamID)
+	m.streams[streamID] = stream
+	m.mutex.Unlock()
+
+	if err := m.writeFrame(muxFrameOpen, streamID, nil); err != nil {
+		m.removeStream(streamID)
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (m *mux) AcceptStream() (Connection, error) {
+	select {
+	case stream := <-m.acceptCh:
+		return stream, nil
+	case <-m.done:
+		return nil, ErrMuxClosed
+	}
+}
+
+func (m *mux) Request(ctx context.Context, data []byte) ([]byte, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancelFunc func()
+		ctx, cancelFunc = context.WithTimeout(ctx, m.opts.RequestTimeout)
+		defer cancelFunc()
+	}
+	conn, err := m.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	stream := conn.(*muxStream)
+	defer stream.Close()
+	if err = stream.writeWithContext(ctx, data); err != nil {
+		return nil, err
+	}
+	return stream.readWithContext(ctx)
+}
+
+func (m *mux) Serve(handler func(request []byte) ([]byte, error)) error {
+	for {
+		conn, err := m.AcceptStream()
+		if err != nil {
+			return err
+		}
+		go func(stream *muxStream) {
+			request, err := stream.Read()
+			if err != nil {
+				stream.Close()
+				return
+			}
+			response, err := handler(request)
+			if err != nil {
+				stream.closeWith(err, true)
+				return
+			}
+			if err = stream.Write(response); err != nil {
+				stream.closeWith(err, true)
+				return
+			}
+			stream.Close()
+		}(conn.(*muxStream))
+	}
+}
+
+func (m *mux) NumStreams() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.streams)
+}
+
+func (m *mux) Close() error {
+	m.closeWith(ErrMuxClosed)
+	return m.conn.Close()
+}
+
+func (m *mux) closeWith(err error) {
+	m.closeOnce.Do(func() {
+		m.mutex.Lock()
+		close(m.done)
+		streams := make([]*muxStream, 0, len(m.streams))
+		for _, stream := range m.streams {
+			streams = append(streams, stream)
+		}
+		m.mutex.Unlock()
+		for _, stream := range streams {
+			stream.closeWith(err, false)
+		}
+	})
+}
+
+type muxStream struct {
+	mux       *mux
+	id        uint32
+	inbound   chan []byte
+	credits   chan struct{}
+	done      chan struct{}
+	mutex     *sync.Mutex
+	state     ConnectionState
+	closed    bool
+	closeErr  error
+	consumed  int
+	onMessage func([]byte)
+	onError   func(error)
+	onClose   func(error)
+}
+
+func newMuxStream(m *mux, id uint32) *muxStream {
+	window := m.opts.StreamWindow
+	s := &muxStream{
+		mux:     m,
+		id:      id,
+		inbound: make(chan []byte, window),
+		credits: make(chan struct{}, window),
+		done:    make(chan struct{}),
+		mutex:   new(sync.Mutex),
+		state:   StateIdle,
+	}
+	s.grantCredits(window)
+	return s
+}
+
+func (s *muxStream) receive(data []byte) {
+	select {
+	case s.inbound <- append([]byte(nil), data...):
+	default:
+		// the peer ignored flow control
+		s.notifyError(ErrMuxWindowExceeded)
+		s.closeWith(ErrMuxWindowExceeded, true)
+	}
+}
+
+func (s *muxStream) grantCredits(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case s.credits <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// consume acknowledges a read message and returns credits to the peer once half of the window is consumed.
+func (s *muxStream) consume() {
+	s.mutex.Lock()
+	s.consumed++
+	if s.consumed < (s.mux.opts.StreamWindow+1)/2 {
+		s.mutex.Unlock()
+		return
+	}
+	credits := s.consumed
+	s.consumed = 0
+	s.mutex.Unlock()
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(credits))
+	s.mux.writeFrame(muxFrameWindow, s.id, payload)
+}
+
+func (s *muxStream) notifyError(err error) {
+	s.mutex.Lock()
+	onError := s.onError
+	s.mutex.Unlock()
+	if onError != nil {
+		onError(err)
+	}
+}
+
+func (s *muxStream) ConnectionType() uint8 {
+	return s.mux.conn.ConnectionType()
+}
+
+func (s *muxStream) Read() ([]byte, error) {
+	return s.readWithContext(context.Background())
+}
+
+// readWithContext returns buffered messages before reporting the close reason of the stream.
+func (s *muxStream) readWithContext(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-s.inbound:
+		s.consume()
+		return data, nil
+	default:
+	}
+	select {
+	case data := <-s.inbound:
+		s.consume()
+		return data, nil
+	case <-s.done:
+		select {
+		case data := <-s.inbound:
+			return data, nil
+		default:
+		}
+		return nil, s.closeReason()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *muxStream) closeReason() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closeErr != nil {
+		return s.closeErr
+	}
+	return ErrConnectionClosed
+}
+
+func (s *muxStream) Write(data []byte) error {
+	return s.writeWithContext(context.Background(), data)
+}
+
+// writeWithContext blocks while the peer's receive window is exhausted.
+func (s *muxStream) writeWithContext(ctx context.Context, data []byte) error {
+	select {
+	case <-s.done:
+		return s.closeReason()
+	default:
+	}
+	select {
+	case <-s.credits:
+	case <-s.done:
+		return s.closeReason()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.mux.writeFrame(muxFrameData, s.id, data)
+}
+
+func (s *muxStream) OnMessage(cb func([]byte)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onMessage = cb
+}
+
+func (s *muxStream) OnError(cb func(error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onError = cb
+}
+
+func (s *muxStream) OnClose(cb func(error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onClose = cb
+}
+
+func (s *muxStream) Address() string {
+	return s.mux.conn.Address()
+}
+
+func (s *muxStream) State() ConnectionState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+func (s *muxStream) setState(state ConnectionState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.state = state
+	}
+}
+
+func (s *muxStream) ReadLoop() {
+	s.setState(StateReading)
+	defer s.setState(StateIdle)
+	for {
+		data, err := s.Read()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		onMessage := s.onMessage
+		s.mutex.Unlock()
+		if onMessage != nil {
+			onMessage(data)
+		}
+	}
+}
+
+func (s *muxStream) String() string {
+	return fmt.Sprintf("MuxStream(%d@%s)", s.id, s.mux.conn.String())
+}
+
+func (s *muxStream) IsLive() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *muxStream) Close() error {
+	s.closeWith(nil, true)
+	return nil
+}
+
+func (s *muxStream) closeRemotely(reason error) {
+	s.closeWith(reason, false)
+}
+
+// closeWith closes the stream and tells the peer about it when notifyPeer is set.
+func (s *muxStream) closeWith(reason error, notifyPeer bool) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	s.closeErr = reason
+	if notifyPeer {
+		s.state = StateStopped
+	} else {
+		s.state = StateDisconnected
+	}
+	onClose := s.onClose
+	close(s.done)
+	s.mutex.Unlock()
+
+	s.mux.removeStream(s.id)
+	if notifyPeer {
+		var payload []byte
+		if reason != nil {
+			payload = []byte(reason.Error())
+		}
+		s.mux.writeFrame(muxFrameClose, s.id, payload)
+	}
+	if onClose != nil {
+		onClose(reason)
+	}
+}
@@ -0,0 +1,171 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestMuxPair(opts *MuxOptions) (Mux, Mux) {
+	a, b := NewPipe()
+	if opts == nil {
+		opts = &MuxOptions{}
+	}
+	clientOpts, serverOpts := *opts, *opts
+	serverOpts.Server = true
+	return NewMux(a, &clientOpts), NewMux(b, &serverOpts)
+}
+
+func TestMuxOpenAcceptStream(t *testing.T) {
+	client, server := newTestMuxPair(nil)
+	defer client.Close()
+
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if err = stream.Write([]byte("hello")); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	accepted, err := server.AcceptStream()
+	if err != nil {
+		t.Fatalf("failed to accept stream: %v", err)
+	}
+	data, err := accepted.Read()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected hello, got %q, %v", data, err)
+	}
+
+	closed := make(chan error, 1)
+	accepted.OnClose(func(err error) { closed <- err })
+	stream.Close()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected remote stream to be closed")
+	}
+	if accepted.IsLive() || accepted.State() != StateDisconnected {
+		t.Errorf("expected accepted stream to be disconnected, got state %d", accepted.State())
+	}
+}
+
+func TestMuxConcurrentRequests(t *testing.T) {
+	client, server := newTestMuxPair(nil)
+	defer client.Close()
+	go server.Serve(func(request []byte) ([]byte, error) {
+		if string(request) == "fail" {
+			return nil, errors.New("handler failed")
+		}
+		time.Sleep(10 * time.Millisecond)
+		return append([]byte("echo:"), request...), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			expected := fmt.Sprintf("echo:%d", i)
+			resp, err := client.Request(context.Background(), []byte(fmt.Sprintf("%d", i)))
+			if err != nil || string(resp) != expected {
+				t.Errorf("expected %s, got %q, %v", expected, resp, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := client.Request(context.Background(), []byte("fail")); err == nil || err.Error() != "handler failed" {
+		t.Errorf("expected handler error to be propagated, got %v", err)
+	}
+}
+
+func TestMuxRequestTimeout(t *testing.T) {
+	client, server := newTestMuxPair(&MuxOptions{RequestTimeout: 20 * time.Millisecond})
+	defer client.Close()
+	go server.Serve(func(request []byte) ([]byte, error) {
+		time.Sleep(200 * time.Millisecond)
+		return request, nil
+	})
+
+	if _, err := client.Request(context.Background(), []byte("slow")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestMuxStreamFlowControl(t *testing.T) {
+	client, server := newTestMuxPair(&MuxOptions{StreamWindow: 2})
+	defer client.Close()
+
+	stream, _ := client.OpenStream()
+	accepted, _ := server.AcceptStream()
+
+	written := make(chan int, 8)
+	go func() {
+		for i := 0; i < 4; i++ {
+			if err := stream.Write([]byte{byte(i)}); err != nil {
+				return
+			}
+			written <- i
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if len(written) != 2 {
+		t.Fatalf("expected writer to block after the window is exhausted, %d writes went through", len(written))
+	}
+	for i := 0; i < 4; i++ {
+		data, err := accepted.Read()
+		if err != nil || data[0] != byte(i) {
+			t.Fatalf("expected message %d, got %v, %v", i, data, err)
+		}
+	}
+}
+
+func TestMuxCloseClosesStreams(t *testing.T) {
+	client, server := newTestMuxPair(nil)
+	stream, _ := client.OpenStream()
+	accepted, _ := server.AcceptStream()
+
+	client.Close()
+	if stream.IsLive() {
+		t.Error("expected local stream to be closed with the mux")
+	}
+	if _, err := accepted.Read(); err == nil {
+		t.Error("expected remote stream read to fail after the mux closes")
+	}
+	if _, err := server.AcceptStream(); !errors.Is(err, ErrConnectionClosed) && !errors.Is(err, ErrMuxClosed) {
+		t.Errorf("expected accept to fail on closed mux, got %v", err)
+	}
+}
+
+func TestMuxRejectsStreamsWithTheLocalParity(t *testing.T) {
+	a, b := NewPipe()
+	client, peer := NewMux(a, nil), NewMux(b, nil)
+	defer client.Close()
+	defer peer.Close()
+
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if _, err = stream.Read(); err == nil || !strings.Contains(err.Error(), ErrMuxProtocol.Error()) {
+		t.Fatalf("expected a protocol error, got %v", err)
+	}
+	if peer.NumStreams() != 0 {
+		t.Errorf("expected the peer not to register the stream, got %d streams", peer.NumStreams())
+	}
+	accepted := make(chan Connection, 1)
+	go func() {
+		if s, err := peer.AcceptStream(); err == nil {
+			accepted <- s
+		}
+	}()
+	select {
+	case <-accepted:
+		t.Error("expected the peer not to accept the stream")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
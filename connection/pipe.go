@@ -0,0 +1,221 @@
+package connection
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dlshle/gommon/utils"
+)
+
+const DefaultPipeBufferSize = 128
+
+type PipeOptions struct {
+	// Latency delays the delivery of every written message.
+	Latency time.Duration
+	// ShouldDrop is consulted on every write; dropped messages are reported as written but never delivered.
+	ShouldDrop func(data []byte) bool
+	// WriteError is consulted on every write; a non-nil error fails the write and the message is not delivered.
+	WriteError func(data []byte) error
+	// BufferSize is the number of undelivered messages per direction before writes block,
+	// DefaultPipeBufferSize is used when <= 0.
+	BufferSize int
+}
+
+type pipeMessage struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+type pipeConnection struct {
+	id        string
+	peer      *pipeConnection
+	opts      PipeOptions
+	inbound   chan pipeMessage
+	done      chan struct{} // shared by both ends, closed when either end closes
+	closeOnce *sync.Once    // shared by both ends
+	mutex     *sync.Mutex
+	state     ConnectionState
+	closed    bool
+	onMessage func([]byte)
+	onError   func(error)
+	onClose   func(error)
+}
+
+// NewPipe returns two connected in-memory connections, anything written to one end is read from the other.
+func NewPipe() (Connection, Connection) {
+	return NewPipeWithOptions(nil)
+}
+
+// NewPipeWithOptions returns two connected in-memory connections with the given latency, drop and error
+// injection applied to writes in both directions.
+func NewPipeWithOptions(opts *PipeOptions) (Connection, Connection) {
+	if opts == nil {
+		opts = &PipeOptions{}
+	}
+	cfg := *opts
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultPipeBufferSize
+	}
+	done := make(chan struct{})
+	closeOnce := new(sync.Once)
+	id := utils.RandomStringWithSize(5)
+	left := newPipeConnection(id+"-0", cfg, done, closeOnce)
+	right := newPipeConnection(id+"-1", cfg, done, closeOnce)
+	left.peer = right
+	right.peer = left
+	return left, right
+}
+
+func newPipeConnection(id string, opts PipeOptions, done chan struct{}, closeOnce *sync.Once) *pipeConnection {
+	return &pipeConnection{
+		id:        id,
+		opts:      opts,
+		inbound:   make(chan pipeMessage, opts.BufferSize),
+		done:      done,
+		closeOnce: closeOnce,
+		mutex:     new(sync.Mutex),
+		state:     StateIdle,
+	}
+}
+
+func (c *pipeConnection) ConnectionType() uint8 {
+	return TypePipe
+}
+
+func (c *pipeConnection) Read() ([]byte, error) {
+	select {
+	case msg := <-c.inbound:
+		if wait := time.Until(msg.deliverAt); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-c.done:
+				return nil, ErrConnectionClosed
+			}
+		}
+		return msg.data, nil
+	case <-c.done:
+		return nil, ErrConnectionClosed
+	}
+}
+
+func (c *pipeConnection) Write(data []byte) error {
+	if !c.IsLive() {
+		return ErrConnectionClosed
+	}
+	if c.opts.WriteError != nil {
+		if err := c.opts.WriteError(data); err != nil {
+			return err
+		}
+	}
+	if c.opts.ShouldDrop != nil && c.opts.ShouldDrop(data) {
+		return nil
+	}
+	msg := pipeMessage{append([]byte(nil), data...), time.Now().Add(c.opts.Latency)}
+	select {
+	case c.peer.inbound <- msg:
+		return nil
+	case <-c.done:
+		return ErrConnectionClosed
+	}
+}
+
+func (c *pipeConnection) OnMessage(cb func([]byte)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onMessage = cb
+}
+
+func (c *pipeConnection) OnError(cb func(error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onError = cb
+}
+
+func (c *pipeConnection) OnClose(cb func(error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onClose = cb
+}
+
+func (c *pipeConnection) Address() string {
+	return "pipe:" + c.id
+}
+
+func (c *pipeConnection) State() ConnectionState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state
+}
+
+func (c *pipeConnection) setState(state ConnectionState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		c.state = state
+	}
+}
+
+// ReadLoop delivers incoming messages to OnMessage until either end of the pipe is closed.
+func (c *pipeConnection) ReadLoop() {
+	c.setState(StateReading)
+	defer c.setState(StateIdle)
+	for {
+		data, err := c.Read()
+		if err == ErrConnectionClosed {
+			return
+		}
+		c.mutex.Lock()
+		onMessage, onError := c.onMessage, c.onError
+		c.mutex.Unlock()
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		if onMessage != nil {
+			onMessage(data)
+		}
+	}
+}
+
+func (c *pipeConnection) String() string {
+	return fmt.Sprintf("Pipe(%s <-> %s)", c.id, c.peer.id)
+}
+
+func (c *pipeConnection) IsLive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close closes both ends of the pipe, firing OnClose on each of them.
+func (c *pipeConnection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.peer.markClosed(StateDisconnected)
+	c.markClosed(StateStopped)
+	return nil
+}
+
+func (c *pipeConnection) markClosed(state ConnectionState) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.closed = true
+	c.state = state
+	onClose := c.onClose
+	c.mutex.Unlock()
+	if onClose != nil {
+		onClose(nil)
+	}
+}
@@ -0,0 +1,105 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPipeReadWrite(t *testing.T) {
+	a, b := NewPipe()
+	defer a.Close()
+
+	if err := a.Write([]byte("hello")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	data, err := b.Read()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected hello, got %q, %v", data, err)
+	}
+	if err := b.Write([]byte("world")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	data, err = a.Read()
+	if err != nil || string(data) != "world" {
+		t.Fatalf("expected world, got %q, %v", data, err)
+	}
+}
+
+func TestPipeReadLoopAndClose(t *testing.T) {
+	a, b := NewPipe()
+	received := make(chan string, 2)
+	closed := make(chan error, 2)
+	b.OnMessage(func(data []byte) { received <- string(data) })
+	a.OnClose(func(err error) { closed <- err })
+	b.OnClose(func(err error) { closed <- err })
+
+	loopDone := make(chan struct{})
+	go func() {
+		b.ReadLoop()
+		close(loopDone)
+	}()
+
+	_ = a.Write([]byte("1"))
+	_ = a.Write([]byte("2"))
+	for _, expected := range []string{"1", "2"} {
+		select {
+		case msg := <-received:
+			if msg != expected {
+				t.Errorf("expected %s, got %s", expected, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+
+	a.Close()
+	select {
+	case <-loopDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected ReadLoop to return after close")
+	}
+	if len(closed) != 2 {
+		t.Errorf("expected OnClose on both ends, got %d", len(closed))
+	}
+	if a.IsLive() || b.IsLive() {
+		t.Error("expected both ends to be closed")
+	}
+	if b.State() != StateDisconnected || a.State() != StateStopped {
+		t.Errorf("unexpected states a=%d b=%d", a.State(), b.State())
+	}
+	if err := b.Write([]byte("3")); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestPipeInjectedFaults(t *testing.T) {
+	injected := errors.New("injected")
+	a, b := NewPipeWithOptions(&PipeOptions{
+		Latency:    30 * time.Millisecond,
+		ShouldDrop: func(data []byte) bool { return string(data) == "drop" },
+		WriteError: func(data []byte) error {
+			if string(data) == "fail" {
+				return injected
+			}
+			return nil
+		},
+	})
+	defer a.Close()
+
+	if err := a.Write([]byte("fail")); !errors.Is(err, injected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	if err := a.Write([]byte("drop")); err != nil {
+		t.Errorf("expected dropped write to succeed, got %v", err)
+	}
+	start := time.Now()
+	_ = a.Write([]byte("keep"))
+	data, err := b.Read()
+	if err != nil || string(data) != "keep" {
+		t.Fatalf("expected only the kept message, got %q, %v", data, err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected latency to be applied, message arrived after %v", elapsed)
+	}
+}
@@ -0,0 +1,353 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dlshle/gommon/observable"
+	"github.com/dlshle/gommon/retry"
+)
+
+const (
+	DefaultMaxBufferedWrites = 128
+
+	// DefaultReconnectAttempts, DefaultReconnectInterval and DefaultReconnectBackoff keep redialing for about
+	// 15 seconds before the connection is closed for good.
+	DefaultReconnectAttempts = 10
+	DefaultReconnectInterval = 200 * time.Millisecond
+	DefaultReconnectBackoff  = 1.5
+)
+
+var (
+	ErrConnectionDisconnected = errors.New("connection is disconnected")
+	ErrConnectionClosed       = errors.New("connection is closed")
+	ErrWriteBufferFull        = errors.New("write buffer is full")
+)
+
+type ReconnectOptions struct {
+	// Retry controls how redials are retried and backed off. When all attempts fail, the connection is closed
+	// for good and OnClose listeners are notified with the last dial error. DefaultReconnectAttempts attempts
+	// backed off from DefaultReconnectInterval are made by default.
+	Retry *retry.RetryOptions
+	// BufferWrites buffers writes while disconnected and flushes them after reconnecting; when false, writes
+	// made while disconnected are rejected with ErrConnectionDisconnected.
+	BufferWrites bool
+	// MaxBufferedWrites caps the number of buffered writes, DefaultMaxBufferedWrites is used when <= 0.
+	MaxBufferedWrites int
+}
+
+// ReconnectingConnection is a Connection that redials through its factory whenever the underlying
+// connection closes. OnClose listeners are only notified when the connection is closed for good.
+type ReconnectingConnection interface {
+	Connection
+	// StateChanges fires on every state transition, including disconnects and successful redials.
+	StateChanges() *observable.SafeObservable[ConnectionState]
+}
+
+type reconnectingConnection struct {
+	ctx           context.Context
+	cancelFunc    func()
+	factory       func() (Connection, error)
+	opts          ReconnectOptions
+	mutex         *sync.Mutex
+	conn          Connection
+	connChanged   chan struct{}
+	closed        bool
+	pendingWrites [][]byte
+	state         *observable.SafeObservable[ConnectionState]
+	onMessage     func([]byte)
+	onError       func(error)
+	onClose       func(error)
+	connType      uint8
+}
+
+func NewReconnectingConnection(factory func() (Connection, error), opts *ReconnectOptions) (ReconnectingConnection, error) {
+	if opts == nil {
+		opts = &ReconnectOptions{}
+	}
+	cfg := *opts
+	// the retry options are copied since retries fix them up in place
+	retryOptions := retry.RetryOptions{
+		MaxRetries: DefaultReconnectAttempts,
+		Interval:   DefaultReconnectInterval,
+		Backoff:    DefaultReconnectBackoff,
+	}
+	if cfg.Retry != nil {
+		retryOptions = *cfg.Retry
+		retryOptions.RetryConditions = append([]func(error) bool(nil), cfg.Retry.RetryConditions...)
+	}
+	cfg.Retry = &retryOptions
+	if cfg.MaxBufferedWrites <= 0 {
+		cfg.MaxBufferedWrites = DefaultMaxBufferedWrites
+	}
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	c := &reconnectingConnection{
+		ctx:         ctx,
+		cancelFunc:  cancelFunc,
+		factory:     factory,
+		opts:        cfg,
+		mutex:       new(sync.Mutex),
+		connChanged: make(chan struct{}),
+		state:       observable.NewSafeObservableWith[ConnectionState](StateDisconnected),
+	}
+	conn, err := c.dial()
+	if err != nil {
+		cancelFunc()
+		return nil, err
+	}
+	c.attach(conn)
+	return c, nil
+}
+
+func (c *reconnectingConnection) dial() (Connection, error) {
+	var conn Connection
+	err := retry.RetryWithBackoffContext(c.ctx, func() (err error) {
+		conn, err = c.factory()
+		return
+	}, retry.WithRetryOptions(c.opts.Retry))
+	return conn, err
+}
+
+// attach makes conn the current connection and flushes writes buffered while disconnected.
+func (c *reconnectingConnection) attach(conn Connection) {
+	conn.OnMessage(func(data []byte) {
+		c.mutex.Lock()
+		onMessage := c.onMessage
+		c.mutex.Unlock()
+		if onMessage != nil {
+			onMessage(data)
+		}
+	})
+	conn.OnError(func(err error) {
+		c.mutex.Lock()
+		onError := c.onError
+		c.mutex.Unlock()
+		if onError != nil {
+			onError(err)
+		}
+	})
+	conn.OnClose(func(err error) {
+		c.handleClose(conn, err)
+	})
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	c.conn = conn
+	c.connType = conn.ConnectionType()
+	pendingWrites := c.pendingWrites
+	c.pendingWrites = nil
+	close(c.connChanged)
+	c.connChanged = make(chan struct{})
+	c.mutex.Unlock()
+
+	for _, data := range pendingWrites {
+		if err := conn.Write(data); err != nil {
+			c.notifyError(fmt.Errorf("flush buffered write: %w", err))
+		}
+	}
+	c.state.Set(conn.State())
+	if !conn.IsLive() {
+		// the connection might have closed before it became the current one
+		c.handleClose(conn, ErrConnectionDisconnected)
+	}
+}
+
+func (c *reconnectingConnection) handleClose(conn Connection, err error) {
+	c.mutex.Lock()
+	if c.closed || c.conn != conn {
+		c.mutex.Unlock()
+		return
+	}
+	c.conn = nil
+	c.mutex.Unlock()
+
+	c.state.Set(StateDisconnected)
+	go c.reconnect(err)
+}
+
+func (c *reconnectingConnection) reconnect(closeErr error) {
+	conn, err := c.dial()
+	if err != nil {
+		if c.ctx.Err() == nil {
+			c.closeWith(fmt.Errorf("reconnect after close(%v) failed: %w", closeErr, err))
+		}
+		return
+	}
+	c.attach(conn)
+}
+
+func (c *reconnectingConnection) notifyError(err error) {
+	c.mutex.Lock()
+	onError := c.onError
+	c.mutex.Unlock()
+	if onError != nil {
+		onError(err)
+	}
+}
+
+func (c *reconnectingConnection) current() (Connection, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil, ErrConnectionClosed
+	}
+	if c.conn == nil {
+		return nil, ErrConnectionDisconnected
+	}
+	return c.conn, nil
+}
+
+func (c *reconnectingConnection) ConnectionType() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.connType
+}
+
+func (c *reconnectingConnection) Read() ([]byte, error) {
+	conn, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Read()
+}
+
+func (c *reconnectingConnection) Write(data []byte) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return ErrConnectionClosed
+	}
+	conn := c.conn
+	if conn == nil {
+		defer c.mutex.Unlock()
+		if !c.opts.BufferWrites {
+			return ErrConnectionDisconnected
+		}
+		if len(c.pendingWrites) >= c.opts.MaxBufferedWrites {
+			return ErrWriteBufferFull
+		}
+		c.pendingWrites = append(c.pendingWrites, append([]byte(nil), data...))
+		return nil
+	}
+	c.mutex.Unlock()
+	return conn.Write(data)
+}
+
+func (c *reconnectingConnection) OnMessage(cb func([]byte)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onMessage = cb
+}
+
+func (c *reconnectingConnection) OnError(cb func(error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onError = cb
+}
+
+func (c *reconnectingConnection) OnClose(cb func(error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onClose = cb
+}
+
+func (c *reconnectingConnection) Address() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn == nil {
+		return ""
+	}
+	return c.conn.Address()
+}
+
+func (c *reconnectingConnection) State() ConnectionState {
+	c.mutex.Lock()
+	conn := c.conn
+	c.mutex.Unlock()
+	if conn != nil {
+		return conn.State()
+	}
+	return c.state.Get()
+}
+
+func (c *reconnectingConnection) StateChanges() *observable.SafeObservable[ConnectionState] {
+	return c.state
+}
+
+// ReadLoop runs the read loop of the current connection and moves on to the redialed connection after each
+// reconnect. It returns once the connection is closed for good.
+func (c *reconnectingConnection) ReadLoop() {
+	for {
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			return
+		}
+		conn := c.conn
+		connChanged := c.connChanged
+		c.mutex.Unlock()
+
+		if conn != nil {
+			conn.ReadLoop()
+			if conn.IsLive() {
+				// the read loop has stopped without the connection being closed
+				return
+			}
+		}
+		select {
+		case <-connChanged:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *reconnectingConnection) String() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn == nil {
+		return "ReconnectingConnection(disconnected)"
+	}
+	return fmt.Sprintf("ReconnectingConnection(%s)", c.conn.String())
+}
+
+func (c *reconnectingConnection) IsLive() bool {
+	conn, err := c.current()
+	return err == nil && conn.IsLive()
+}
+
+func (c *reconnectingConnection) Close() error {
+	return c.closeWith(nil)
+}
+
+func (c *reconnectingConnection) closeWith(reason error) (err error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	c.conn = nil
+	c.pendingWrites = nil
+	onClose := c.onClose
+	c.mutex.Unlock()
+
+	c.state.Set(StateClosing)
+	c.cancelFunc()
+	if conn != nil {
+		err = conn.Close()
+	}
+	c.state.Set(StateStopped)
+	if onClose != nil {
+		onClose(reason)
+	}
+	return
+}
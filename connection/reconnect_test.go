@@ -0,0 +1,219 @@
+package connection
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dlshle/gommon/retry"
+)
+
+type fakeConnection struct {
+	mutex     sync.Mutex
+	id        int
+	live      bool
+	written   [][]byte
+	onWrite   func([]byte)
+	onMessage func([]byte)
+	onClose   func(error)
+}
+
+func (f *fakeConnection) ConnectionType() uint8  { return TypeTCP }
+func (f *fakeConnection) Read() ([]byte, error)  { return nil, nil }
+func (f *fakeConnection) Address() string        { return "fake" }
+func (f *fakeConnection) OnError(func(error))    {}
+func (f *fakeConnection) ReadLoop()              {}
+func (f *fakeConnection) String() string         { return "fake" }
+func (f *fakeConnection) State() ConnectionState { return StateIdle }
+func (f *fakeConnection) OnClose(cb func(error)) { f.onClose = cb }
+
+func (f *fakeConnection) IsLive() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.live
+}
+
+func (f *fakeConnection) Close() error {
+	f.drop(nil)
+	return nil
+}
+
+func (f *fakeConnection) writes() [][]byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.written
+}
+
+func (f *fakeConnection) Write(data []byte) error {
+	f.mutex.Lock()
+	f.written = append(f.written, data)
+	onWrite := f.onWrite
+	f.mutex.Unlock()
+	if onWrite != nil {
+		onWrite(data)
+	}
+	return nil
+}
+
+func (f *fakeConnection) OnMessage(cb func([]byte)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.onMessage = cb
+}
+
+func (f *fakeConnection) receive(data []byte) {
+	f.mutex.Lock()
+	onMessage := f.onMessage
+	f.mutex.Unlock()
+	if onMessage != nil {
+		onMessage(data)
+	}
+}
+
+func (f *fakeConnection) drop(err error) {
+	f.mutex.Lock()
+	if !f.live {
+		f.mutex.Unlock()
+		return
+	}
+	f.live = false
+	f.mutex.Unlock()
+	if f.onClose != nil {
+		f.onClose(err)
+	}
+}
+
+type fakeDialer struct {
+	mutex sync.Mutex
+	conns []*fakeConnection
+	fail  bool
+}
+
+func (d *fakeDialer) dial() (Connection, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.fail {
+		return nil, errors.New("dial failed")
+	}
+	conn := &fakeConnection{id: len(d.conns), live: true}
+	d.conns = append(d.conns, conn)
+	return conn, nil
+}
+
+func (d *fakeDialer) last() *fakeConnection {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.conns[len(d.conns)-1]
+}
+
+func waitForState(t *testing.T, c ReconnectingConnection, state ConnectionState) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if c.State() == state {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected state %d, got %d", state, c.State())
+}
+
+func TestReconnectingConnectionRedialsAndFlushesBufferedWrites(t *testing.T) {
+	dialer := &fakeDialer{}
+	c, err := NewReconnectingConnection(dialer.dial, &ReconnectOptions{
+		Retry:        &retry.RetryOptions{MaxRetries: 3, Interval: 20 * time.Millisecond},
+		BufferWrites: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	var statesMutex sync.Mutex
+	var states []ConnectionState
+	c.StateChanges().On(func(s ConnectionState) {
+		statesMutex.Lock()
+		states = append(states, s)
+		statesMutex.Unlock()
+	})
+
+	first := dialer.last()
+	dialer.mutex.Lock()
+	dialer.fail = true
+	dialer.mutex.Unlock()
+	first.drop(errors.New("peer reset"))
+	waitForState(t, c, StateDisconnected)
+
+	if err := c.Write([]byte("buffered")); err != nil {
+		t.Fatalf("expected write to be buffered, got %v", err)
+	}
+	dialer.mutex.Lock()
+	dialer.fail = false
+	dialer.mutex.Unlock()
+	waitForState(t, c, StateIdle)
+
+	second := dialer.last()
+	if second == first {
+		t.Fatal("expected a new connection after reconnect")
+	}
+	if writes := second.writes(); len(writes) != 1 || string(writes[0]) != "buffered" {
+		t.Errorf("expected buffered write to be flushed, got %v", writes)
+	}
+	statesMutex.Lock()
+	defer statesMutex.Unlock()
+	if len(states) < 2 || states[0] != StateDisconnected || states[len(states)-1] != StateIdle {
+		t.Errorf("unexpected state transitions: %v", states)
+	}
+}
+
+func TestReconnectingConnectionRejectsWritesWhenNotBuffering(t *testing.T) {
+	dialer := &fakeDialer{}
+	c, err := NewReconnectingConnection(dialer.dial, &ReconnectOptions{
+		Retry: &retry.RetryOptions{MaxRetries: 50, Interval: 20 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	dialer.mutex.Lock()
+	dialer.fail = true
+	dialer.mutex.Unlock()
+	dialer.last().drop(nil)
+	waitForState(t, c, StateDisconnected)
+
+	if err := c.Write([]byte("data")); !errors.Is(err, ErrConnectionDisconnected) {
+		t.Errorf("expected ErrConnectionDisconnected, got %v", err)
+	}
+}
+
+func TestReconnectingConnectionClosesAfterRetriesExhausted(t *testing.T) {
+	dialer := &fakeDialer{}
+	retryOptions := &retry.RetryOptions{MaxRetries: 2, Interval: 5 * time.Millisecond}
+	c, err := NewReconnectingConnection(dialer.dial, &ReconnectOptions{Retry: retryOptions})
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	closed := make(chan error, 1)
+	c.OnClose(func(err error) { closed <- err })
+
+	dialer.mutex.Lock()
+	dialer.fail = true
+	dialer.mutex.Unlock()
+	dialer.last().drop(nil)
+
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Error("expected OnClose to receive the dial error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnClose after reconnect attempts are exhausted")
+	}
+	if err := c.Write([]byte("data")); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+	if retryOptions.Backoff != 0 {
+		t.Errorf("expected the caller's retry options to be left alone, got backoff %v", retryOptions.Backoff)
+	}
+}
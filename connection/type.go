@@ -9,6 +9,7 @@ func init() {
 	typeStringMap[TypeUDP] = "UDP"
 	typeStringMap[TypeRTC] = "RTC"
 	typeStringMap[TypeHTTP] = "HTTP"
+	typeStringMap[TypePipe] = "PIPE"
 }
 
 const (
@@ -17,10 +18,11 @@ const (
 	TypeUDP
 	TypeRTC
 	TypeHTTP
+	TypePipe
 )
 
 func IsAsyncType(connType uint8) bool {
-	return connType < TypeHTTP
+	return connType < TypeHTTP || connType == TypePipe
 }
 
 func TypeString(connType uint8) string {
@@ -0,0 +1,301 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTokenRefreshAhead = 30 * time.Second
+
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	HeaderAmzDate        = "X-Amz-Date"
+	HeaderAmzContentHash = "X-Amz-Content-Sha256"
+)
+
+// withAuthorization returns a copy of request with the Authorization header set, the caller's request is kept as is.
+func withAuthorization(request *Request, value string) *Request {
+	authorized := request.Clone(request.Context())
+	if authorized.Header == nil {
+		authorized.Header = http.Header{}
+	}
+	authorized.Header.Set("Authorization", value)
+	return authorized
+}
+
+// BasicAuthInterceptor sets a static basic auth Authorization header on every request.
+func BasicAuthInterceptor(username, password string) Interceptor {
+	probe := &http.Request{Header: http.Header{}}
+	probe.SetBasicAuth(username, password)
+	authorization := probe.Header.Get("Authorization")
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		return next(withAuthorization(request, authorization))
+	}
+}
+
+type Token struct {
+	AccessToken string
+	// TokenType is the authorization scheme, Bearer if empty.
+	TokenType string
+	// Expiry is zero for tokens that never expire.
+	Expiry time.Time
+}
+
+func (t *Token) expiresWithin(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(d).After(t.Expiry)
+}
+
+func (t *Token) authorization() string {
+	if t.TokenType == "" {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
+
+// TokenSource supplies the tokens of BearerTokenInterceptor.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenInvalidator is implemented by token sources that can drop a token the server rejected, such as
+// *CachedTokenSource.
+type TokenInvalidator interface {
+	Invalidate(token *Token)
+}
+
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+func StaticTokenSource(accessToken string) TokenSource {
+	token := &Token{AccessToken: accessToken}
+	return TokenSourceFunc(func(context.Context) (*Token, error) {
+		return token, nil
+	})
+}
+
+// CachedTokenSource reuses the token of its source until it is about to expire, concurrent callers share a
+// single refresh.
+type CachedTokenSource struct {
+	source       TokenSource
+	refreshAhead time.Duration
+	mutex        *sync.Mutex
+	token        *Token
+}
+
+// NewCachedTokenSource refreshes tokens refreshAhead before they expire, DefaultTokenRefreshAhead if not positive.
+func NewCachedTokenSource(source TokenSource, refreshAhead time.Duration) *CachedTokenSource {
+	if refreshAhead <= 0 {
+		refreshAhead = DefaultTokenRefreshAhead
+	}
+	return &CachedTokenSource{source: source, refreshAhead: refreshAhead, mutex: new(sync.Mutex)}
+}
+
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token != nil && !s.token.expiresWithin(s.refreshAhead) {
+		return s.token, nil
+	}
+	token, err := s.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.AccessToken == "" {
+		return nil, errors.New("token source returned an empty token")
+	}
+	s.token = token
+	return token, nil
+}
+
+// Invalidate drops the cached token if it is still current, the next call fetches a new one.
+func (s *CachedTokenSource) Invalidate(token *Token) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token == token {
+		s.token = nil
+	}
+}
+
+// BearerTokenInterceptor authorizes requests with tokens from source. When the server answers 401 and source is
+// a TokenInvalidator, the token is invalidated and the request is sent once more with a fresh token.
+func BearerTokenInterceptor(source TokenSource) Interceptor {
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		token, err := source.Token(request.Context())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := next(withAuthorization(request, token.authorization()))
+		invalidator, ok := source.(TokenInvalidator)
+		if err != nil || !ok || resp.Code != http.StatusUnauthorized {
+			return resp, err
+		}
+		invalidator.Invalidate(token)
+		refreshed, err := source.Token(request.Context())
+		if err != nil {
+			return resp, nil
+		}
+		resp.Close()
+		return next(withAuthorization(request, refreshed.authorization()))
+	}
+}
+
+type HMACSigningOptions struct {
+	AccessKeyID string
+	SecretKey   string
+	Region      string
+	Service     string
+	// SignedHeaders are signed in addition to Host and X-Amz-Date when present on the request.
+	SignedHeaders []string
+	// ContentHashHeader sets X-Amz-Content-Sha256 to the body hash, some services require it.
+	ContentHashHeader bool
+	// Now is the clock of the signature, time.Now by default.
+	Now func() time.Time
+}
+
+// HMACSigningInterceptor signs requests with AWS Signature Version 4, see SignRequest.
+func HMACSigningInterceptor(opts *HMACSigningOptions) Interceptor {
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		signed := request.Clone(request.Context())
+		if err := SignRequest(signed, opts); err != nil {
+			return nil, err
+		}
+		return next(signed)
+	}
+}
+
+// SignRequest adds an AWS Signature Version 4 Authorization header covering the method, path, query, signed
+// headers and the SHA-256 of the body. The body is buffered so the request stays replayable.
+func SignRequest(request *Request, opts *HMACSigningOptions) error {
+	if opts.AccessKeyID == "" || opts.SecretKey == "" {
+		return errors.New("signing requires an access key id and a secret key")
+	}
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	timestamp := now().UTC()
+	body, err := snapshotRequestBody(request)
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256Hex(body.Bytes())
+	if request.Header == nil {
+		request.Header = http.Header{}
+	}
+	request.Header.Set(HeaderAmzDate, timestamp.Format(sigV4TimeFormat))
+	if opts.ContentHashHeader {
+		request.Header.Set(HeaderAmzContentHash, payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := canonicalizeHeaders(request, opts)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		canonicalPath(request.URL),
+		canonicalQuery(request.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{timestamp.Format(sigV4DateFormat), opts.Region, opts.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, timestamp.Format(sigV4TimeFormat), scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + opts.SecretKey)
+	for _, part := range []string{timestamp.Format(sigV4DateFormat), opts.Region, opts.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", sigV4Algorithm+" Credential="+opts.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+func canonicalizeHeaders(request *Request, opts *HMACSigningOptions) (canonical string, signed string) {
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	values := map[string]string{"host": host}
+	names := []string{"host", strings.ToLower(HeaderAmzDate)}
+	if opts.ContentHashHeader {
+		names = append(names, strings.ToLower(HeaderAmzContentHash))
+	}
+	for _, header := range opts.SignedHeaders {
+		if request.Header.Get(header) != "" {
+			names = append(names, strings.ToLower(header))
+		}
+	}
+	sort.Strings(names)
+	var builder strings.Builder
+	unique := names[:0]
+	for i, name := range names {
+		if i > 0 && name == names[i-1] {
+			continue
+		}
+		unique = append(unique, name)
+		value, ok := values[name]
+		if !ok {
+			trimmed := append([]string(nil), request.Header.Values(name)...)
+			for j := range trimmed {
+				trimmed[j] = strings.Join(strings.Fields(trimmed[j]), " ")
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		builder.WriteString(name + ":" + value + "\n")
+	}
+	return builder.String(), strings.Join(unique, ";")
+}
+
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBasicAuthInterceptor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(BasicAuthInterceptor("user", "secret")).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected authorized request, got %v, %v", resp, err)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("expected the caller's request to be left untouched")
+	}
+}
+
+func TestBearerTokenInterceptorRefreshesOnUnauthorized(t *testing.T) {
+	var current atomic.Value
+	current.Store("token-1")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	var fetches int32
+	source := NewCachedTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return &Token{AccessToken: "token-" + string(rune('0'+n)), Expiry: time.Now().Add(time.Hour)}, nil
+	}), time.Minute)
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(BearerTokenInterceptor(source)).Build()
+	defer c.Stop()
+
+	doRequest := func() *Response {
+		req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).StringBody("payload").Build()
+		resp, err := c.Request(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := doRequest(); resp.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.Code)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected the token to be cached, fetched %d times", n)
+	}
+
+	// the server rotates the token, the interceptor retries once with a fresh one
+	current.Store("token-2")
+	if resp := doRequest(); resp.Code != http.StatusOK || string(resp.Body) != "Bearer token-2" {
+		t.Fatalf("expected a retry with the refreshed token, got %d %s", resp.Code, resp.Body)
+	}
+
+	current.Store("revoked")
+	if resp := doRequest(); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after a single retry, got %d", resp.Code)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 3 {
+		t.Errorf("expected 3 token fetches, got %d", n)
+	}
+}
+
+type rotatingTokenSource struct {
+	current     atomic.Int32
+	invalidated atomic.Int32
+}
+
+func (s *rotatingTokenSource) Token(ctx context.Context) (*Token, error) {
+	return &Token{AccessToken: "token-" + string(rune('0'+s.current.Load()))}, nil
+}
+
+func (s *rotatingTokenSource) Invalidate(token *Token) {
+	s.invalidated.Add(1)
+	s.current.Add(1)
+}
+
+func TestBearerTokenInterceptorInvalidatesCustomSources(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	source := &rotatingTokenSource{}
+	source.current.Store(1)
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(BearerTokenInterceptor(source)).Build()
+	defer c.Stop()
+	req, _ := NewRequestBuilder().URL(ts.URL).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK || source.invalidated.Load() != 1 {
+		t.Errorf("expected a retry after invalidating the token, got %v, %v with %d invalidations", resp, err, source.invalidated.Load())
+	}
+}
+
+func TestCachedTokenSourceRefreshesAheadOfExpiry(t *testing.T) {
+	var fetches int32
+	source := NewCachedTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		return &Token{AccessToken: "token", Expiry: time.Now().Add(time.Minute)}, nil
+	}), 2*time.Minute)
+	for i := 0; i < 3; i++ {
+		if _, err := source.Token(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 3 {
+		t.Errorf("expected tokens expiring within the refresh window to be refetched, got %d fetches", n)
+	}
+}
+
+func TestSignRequestMatchesSigV4TestSuite(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	// requests without headers get them
+	req, _ := NewRequestBuilder().URL("https://example.amazonaws.com/").Build()
+	req.Header = nil
+	err := SignRequest(req, &HMACSigningOptions{
+		AccessKeyID: "AKIDEXAMPLE",
+		SecretKey:   "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:      "us-east-1",
+		Service:     "service",
+		Now: func() time.Time {
+			return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if authorization := req.Header.Get("Authorization"); authorization != expected {
+		t.Errorf("unexpected authorization\n%s\nexpected\n%s", authorization, expected)
+	}
+}
+
+func TestHMACSigningInterceptorSignsBody(t *testing.T) {
+	var authorization, contentHash, body atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		contentHash.Store(r.Header.Get(HeaderAmzContentHash))
+		data := make([]byte, 16)
+		n, _ := r.Body.Read(data)
+		body.Store(string(data[:n]))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(HMACSigningInterceptor(&HMACSigningOptions{
+		AccessKeyID:       "key",
+		SecretKey:         "secret",
+		Region:            "local",
+		Service:           "api",
+		SignedHeaders:     []string{"Content-Type"},
+		ContentHashHeader: true,
+	})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().Method(http.MethodPut).URL(ts.URL + "/items?b=2&a=1").
+		Header(http.Header{"Content-Type": {"text/plain"}}).StringBody("signed").Build()
+	if _, err := c.Request(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if !strings.Contains(authorization.Load().(string), "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date,") {
+		t.Errorf("unexpected authorization %s", authorization.Load())
+	}
+	if contentHash.Load() != sha256Hex([]byte("signed")) || body.Load() != "signed" {
+		t.Errorf("expected the body to be hashed and sent, got %v, %v", contentHash.Load(), body.Load())
+	}
+}
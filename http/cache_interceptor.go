@@ -0,0 +1,263 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlshle/gommon/cache"
+)
+
+const (
+	// HeaderXCache reports how the caching interceptor produced a response
+	HeaderXCache = "X-Cache"
+
+	CacheStatusHit         = "HIT"
+	CacheStatusMiss        = "MISS"
+	CacheStatusRevalidated = "REVALIDATED"
+	CacheStatusStale       = "STALE"
+
+	DefaultCacheCapacity = 1024
+	DefaultCacheMaxStale = 24 * time.Hour
+)
+
+// CachedResponse is a response stored by the caching interceptor.
+type CachedResponse struct {
+	Code      int
+	Header    http.Header
+	Body      []byte
+	URI       string
+	StoredAt  time.Time
+	ExpiresAt time.Time
+	// Vary holds the values of the request headers named by the Vary header of the response, the response is
+	// only served to requests with the same values.
+	Vary http.Header
+}
+
+func (r *CachedResponse) isFresh(now time.Time) bool {
+	return now.Before(r.ExpiresAt)
+}
+
+func (r *CachedResponse) matchesVary(header http.Header) bool {
+	for name, values := range r.Vary {
+		if !slices.Equal(values, header.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CachedResponse) hasValidators() bool {
+	return r.Header.Get("ETag") != "" || r.Header.Get("Last-Modified") != ""
+}
+
+func (r *CachedResponse) toResponse(status string) *Response {
+	header := r.Header.Clone()
+	header.Set(HeaderXCache, status)
+	return &Response{Code: r.Code, Header: header, Body: append([]byte(nil), r.Body...), URI: r.URI}
+}
+
+type CacheOptions struct {
+	// Cache stores the responses, an LRU cache of DefaultCacheCapacity entries is used if not set.
+	Cache cache.Cache[string, *CachedResponse]
+	// KeyFunc keys the cache, the request URL by default. Requests with an Authorization header bypass the cache
+	// unless KeyFunc is set, it is then up to KeyFunc to keep the responses of different credentials apart.
+	KeyFunc func(request *Request) string
+	// DefaultTTL is the freshness of responses without max-age or Expires, 0 requires revalidation.
+	DefaultTTL time.Duration
+	// MaxStale is how long past expiry a response is still served when the origin fails, negative disables it.
+	MaxStale time.Duration
+}
+
+// CacheInterceptor serves GET requests from a cache following Cache-Control(max-age, no-cache, no-store) and
+// Expires. Expired responses with an ETag or Last-Modified are revalidated with If-None-Match/If-Modified-Since,
+// and served stale when the origin fails with an error or a 5xx. Every response it returns carries an X-Cache
+// header with one of the CacheStatus values. Successful unsafe requests evict the cached response of their key.
+// Responses are only served to requests matching the request header values they Vary on, one response is kept
+// per key so variants replace each other.
+func CacheInterceptor(opts *CacheOptions) Interceptor {
+	options := CacheOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	keyedByCaller := options.KeyFunc != nil
+	if options.Cache == nil {
+		options.Cache = cache.NewLRUCache[string, *CachedResponse](DefaultCacheCapacity)
+	}
+	if options.KeyFunc == nil {
+		options.KeyFunc = func(request *Request) string {
+			return request.URL.String()
+		}
+	}
+	if options.MaxStale == 0 {
+		options.MaxStale = DefaultCacheMaxStale
+	}
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		if request.Method != http.MethodGet {
+			resp, err := next(request)
+			if err == nil && !isSafeMethod(request.Method) && resp.Code < 400 {
+				options.Cache.Delete(options.KeyFunc(request))
+			}
+			return resp, err
+		}
+		if isStreamResponseRequested(request.Context()) ||
+			hasCacheDirective(request.Header, "no-store") ||
+			request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != "" ||
+			(!keyedByCaller && request.Header.Get("Authorization") != "") {
+			// conditional requests of the caller are answered by the origin, so are authorized ones since the
+			// default key would share their responses with other credentials
+			return next(request)
+		}
+		key := options.KeyFunc(request)
+		now := time.Now()
+		entry, cached := options.Cache.Get(key)
+		cached = cached && entry.matchesVary(request.Header)
+		if cached && entry.isFresh(now) && !hasCacheDirective(request.Header, "no-cache") {
+			return entry.toResponse(CacheStatusHit), nil
+		}
+
+		outgoing := request
+		if cached && entry.hasValidators() {
+			outgoing = request.Clone(request.Context())
+			if outgoing.Header == nil {
+				outgoing.Header = http.Header{}
+			}
+			if etag := entry.Header.Get("ETag"); etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+		resp, err := next(outgoing)
+
+		if err != nil || resp.Code >= 500 {
+			if cached && options.MaxStale > 0 && now.Before(entry.ExpiresAt.Add(options.MaxStale)) {
+				resp.Close()
+				return entry.toResponse(CacheStatusStale), nil
+			}
+			if err == nil {
+				resp.Header = withHeader(resp.Header, HeaderXCache, CacheStatusMiss)
+			}
+			return resp, err
+		}
+		if resp.Code == http.StatusNotModified && cached {
+			refreshed := *entry
+			refreshed.Header = entry.Header.Clone()
+			for _, name := range []string{"Cache-Control", "Date", "ETag", "Expires", "Last-Modified"} {
+				if values := resp.Header.Values(name); len(values) > 0 {
+					refreshed.Header[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			refreshed.StoredAt = now
+			refreshed.ExpiresAt = now.Add(freshnessLifetime(refreshed.Header, options.DefaultTTL))
+			options.Cache.Set(key, &refreshed)
+			return refreshed.toResponse(CacheStatusRevalidated), nil
+		}
+		if vary, ok := varyHeader(request.Header, resp.Header); ok && resp.Code == http.StatusOK &&
+			!hasCacheDirective(resp.Header, "no-store") {
+			options.Cache.Set(key, &CachedResponse{
+				Code:      resp.Code,
+				Header:    resp.Header.Clone(),
+				Body:      append([]byte(nil), resp.Body...),
+				URI:       resp.URI,
+				StoredAt:  now,
+				ExpiresAt: now.Add(freshnessLifetime(resp.Header, options.DefaultTTL)),
+				Vary:      vary,
+			})
+		}
+		resp.Header = withHeader(resp.Header, HeaderXCache, CacheStatusMiss)
+		return resp, nil
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// withHeader returns a copy of header with key set, interceptors use it to annotate responses they pass on.
+func withHeader(header http.Header, key, value string) http.Header {
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(key, value)
+	return header
+}
+
+// varyHeader returns the values of the request headers the response varies on, false if it varies on "*" and
+// can not be reused.
+func varyHeader(request, response http.Header) (http.Header, bool) {
+	var vary http.Header
+	for _, value := range response.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			if vary == nil {
+				vary = http.Header{}
+			}
+			vary[http.CanonicalHeaderKey(name)] = slices.Clone(request.Values(name))
+		}
+	}
+	return vary, true
+}
+
+// cacheDirective returns the value of a Cache-Control directive and whether it is present.
+func cacheDirective(header http.Header, name string) (string, bool) {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			key, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(key, name) {
+				return strings.Trim(arg, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasCacheDirective(header http.Header, name string) bool {
+	_, ok := cacheDirective(header, name)
+	return ok
+}
+
+// freshnessLifetime computes how long a response stays fresh from max-age or Expires, minus its Age.
+func freshnessLifetime(header http.Header, defaultTTL time.Duration) time.Duration {
+	if hasCacheDirective(header, "no-cache") {
+		return 0
+	}
+	lifetime := defaultTTL
+	if maxAge, ok := cacheDirective(header, "max-age"); ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return 0
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		lifetime = expiresAt.Sub(date)
+	}
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	if lifetime < 0 {
+		return 0
+	}
+	return lifetime
+}
@@ -0,0 +1,210 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheInterceptorHonorsMaxAge(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		}
+		w.Write([]byte("cached"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(CacheInterceptor(&CacheOptions{})).Build()
+	defer c.Stop()
+
+	get := func(path string) *Response {
+		req, _ := NewRequestBuilder().URL(ts.URL + path).Build()
+		resp, err := c.Request(req)
+		if err != nil || resp.Code != http.StatusOK || string(resp.Body) != "cached" {
+			t.Fatalf("unexpected response %v, %v", resp, err)
+		}
+		return resp
+	}
+
+	if status := get("/items").Header.Get(HeaderXCache); status != CacheStatusMiss {
+		t.Errorf("expected first request to miss, got %s", status)
+	}
+	if status := get("/items").Header.Get(HeaderXCache); status != CacheStatusHit {
+		t.Errorf("expected second request to hit, got %s", status)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("expected 1 request to the origin, got %d", n)
+	}
+
+	get("/private")
+	if status := get("/private").Header.Get(HeaderXCache); status != CacheStatusMiss {
+		t.Errorf("expected no-store responses not to be cached, got %s", status)
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("expected 3 requests to the origin, got %d", n)
+	}
+
+	req, _ := NewRequestBuilder().Method(http.MethodDelete).URL(ts.URL + "/items").Build()
+	if _, err := c.Request(req); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if status := get("/items").Header.Get(HeaderXCache); status != CacheStatusMiss {
+		t.Errorf("expected unsafe requests to evict the cached response, got %s", status)
+	}
+}
+
+func TestCacheInterceptorHonorsVary(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language") + r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(CacheInterceptor(nil)).Build()
+	defer c.Stop()
+
+	get := func(c Client, header http.Header) *Response {
+		req, _ := NewRequestBuilder().URL(ts.URL).Header(header).Build()
+		resp, err := c.Request(req)
+		if err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("unexpected response %v, %v", resp, err)
+		}
+		return resp
+	}
+	for i, step := range []struct {
+		language string
+		status   string
+	}{{"en", CacheStatusMiss}, {"en", CacheStatusHit}, {"fr", CacheStatusMiss}, {"fr", CacheStatusHit}, {"en", CacheStatusMiss}} {
+		resp := get(c, http.Header{"Accept-Language": {step.language}})
+		if status := resp.Header.Get(HeaderXCache); status != step.status || string(resp.Body) != step.language {
+			t.Errorf("request %d: expected %s for %s, got %s with %s", i, step.status, step.language, status, resp.Body)
+		}
+	}
+
+	// authorized requests bypass the cache unless the caller keys it
+	for _, token := range []string{"a", "b"} {
+		resp := get(c, http.Header{"Accept-Language": {"en"}, "Authorization": {token}})
+		if resp.Header.Get(HeaderXCache) != "" || string(resp.Body) != "en"+token {
+			t.Errorf("expected authorized request to reach the origin, got %s with %s", resp.Header.Get(HeaderXCache), resp.Body)
+		}
+	}
+	keyed := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(CacheInterceptor(&CacheOptions{
+		KeyFunc: func(request *Request) string {
+			return request.Header.Get("Authorization") + " " + request.URL.String()
+		},
+	})).Build()
+	defer keyed.Stop()
+	get(keyed, http.Header{"Authorization": {"a"}})
+	if resp := get(keyed, http.Header{"Authorization": {"a"}}); resp.Header.Get(HeaderXCache) != CacheStatusHit {
+		t.Errorf("expected authorized responses to be cached with a caller key, got %s", resp.Header.Get(HeaderXCache))
+	}
+	if n := atomic.LoadInt32(&hits); n != 6 {
+		t.Errorf("expected 6 requests to the origin, got %d", n)
+	}
+}
+
+func TestCacheInterceptorRevalidatesWithETag(t *testing.T) {
+	var hits, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("v1 body"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(CacheInterceptor(&CacheOptions{})).Build()
+	defer c.Stop()
+
+	for i, expected := range []string{CacheStatusMiss, CacheStatusRevalidated, CacheStatusRevalidated} {
+		req, _ := NewRequestBuilder().URL(ts.URL).Build()
+		resp, err := c.Request(req)
+		if err != nil || resp.Code != http.StatusOK || string(resp.Body) != "v1 body" {
+			t.Fatalf("request %d: unexpected response %v, %v", i, resp, err)
+		}
+		if status := resp.Header.Get(HeaderXCache); status != expected {
+			t.Errorf("request %d: expected %s, got %s", i, expected, status)
+		}
+	}
+	if atomic.LoadInt32(&hits) != 3 || atomic.LoadInt32(&notModified) != 2 {
+		t.Errorf("expected 2 conditional requests out of 3, got %d of %d", notModified, hits)
+	}
+}
+
+func TestCacheInterceptorServesStaleOnError(t *testing.T) {
+	var failing atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write([]byte("fresh"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(CacheInterceptor(&CacheOptions{})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Build()
+	if _, err := c.Request(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	failing.Store(true)
+	req, _ = NewRequestBuilder().URL(ts.URL).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK || string(resp.Body) != "fresh" {
+		t.Fatalf("expected the stale response, got %v, %v", resp, err)
+	}
+	if status := resp.Header.Get(HeaderXCache); status != CacheStatusStale {
+		t.Errorf("expected %s, got %s", CacheStatusStale, status)
+	}
+
+	c2 := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(CacheInterceptor(&CacheOptions{MaxStale: -1})).Build()
+	defer c2.Stop()
+	failing.Store(false)
+	req, _ = NewRequestBuilder().URL(ts.URL).Build()
+	c2.Request(req)
+	failing.Store(true)
+	req, _ = NewRequestBuilder().URL(ts.URL).Build()
+	if resp, err = c2.Request(req); err != nil || resp.Code != http.StatusBadGateway {
+		t.Errorf("expected the origin error when stale responses are disabled, got %v, %v", resp, err)
+	}
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	now := time.Now().UTC()
+	cases := []struct {
+		header   http.Header
+		expected time.Duration
+	}{
+		{http.Header{"Cache-Control": {"max-age=120"}}, 120 * time.Second},
+		{http.Header{"Cache-Control": {"max-age=120"}, "Age": {"20"}}, 100 * time.Second},
+		{http.Header{"Cache-Control": {"max-age=120, no-cache"}}, 0},
+		{http.Header{"Date": {now.Format(http.TimeFormat)}, "Expires": {now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{http.Header{"Expires": {"0"}}, 0},
+		{http.Header{}, 5 * time.Second},
+	}
+	for i, c := range cases {
+		if lifetime := freshnessLifetime(c.header, 5*time.Second); lifetime != c.expected {
+			t.Errorf("case %d: expected %s, got %s", i, c.expected, lifetime)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type CassetteMode int
+
+const (
+	// CassetteModeReplay answers requests from the cassette file and fails on requests it has no recording for.
+	CassetteModeReplay CassetteMode = 0
+	// CassetteModeRecord sends requests through a real client and records every exchange.
+	CassetteModeRecord CassetteMode = 1
+)
+
+var ErrCassetteUnmatched = errors.New("no recorded interaction matches the request")
+
+type CassetteRequest struct {
+	Method string       `json:"method" yaml:"method"`
+	URL    string       `json:"url" yaml:"url"`
+	Header http.Header  `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   CassetteBody `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	Code   int          `json:"code" yaml:"code"`
+	Header http.Header  `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   CassetteBody `json:"body,omitempty" yaml:"body,omitempty"`
+	URI    string       `json:"uri,omitempty" yaml:"uri,omitempty"`
+}
+
+// CassetteBody is a recorded body. Bodies that are valid UTF-8 are written as JSON strings so cassettes stay
+// readable, others such as compressed or binary payloads are written as {"base64": "..."}.
+type CassetteBody []byte
+
+type cassetteBase64Body struct {
+	Base64 string `json:"base64"`
+}
+
+func (b CassetteBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(cassetteBase64Body{Base64: base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *CassetteBody) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var encoded cassetteBase64Body
+		if err := json.Unmarshal(trimmed, &encoded); err != nil {
+			return err
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+		if err != nil {
+			return fmt.Errorf("decode base64 cassette body: %w", err)
+		}
+		*b = decoded
+		return nil
+	}
+	var text *string
+	if err := json.Unmarshal(trimmed, &text); err != nil {
+		return err
+	}
+	if text == nil {
+		*b = nil
+	} else {
+		*b = CassetteBody(*text)
+	}
+	return nil
+}
+
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request" yaml:"request"`
+	Response CassetteResponse `json:"response" yaml:"response"`
+}
+
+type Cassette struct {
+	Interactions []*CassetteInteraction `json:"interactions" yaml:"interactions"`
+}
+
+// CassetteCodec encodes cassettes on disk, JSONCassetteCodec by default. Cassette types carry yaml tags so a YAML
+// codec can be built on any YAML library without gommon depending on one.
+type CassetteCodec interface {
+	Marshal(cassette *Cassette) ([]byte, error)
+	Unmarshal(data []byte, cassette *Cassette) error
+}
+
+type jsonCassetteCodec struct{}
+
+func (jsonCassetteCodec) Marshal(cassette *Cassette) ([]byte, error) {
+	return json.MarshalIndent(cassette, "", "  ")
+}
+
+func (jsonCassetteCodec) Unmarshal(data []byte, cassette *Cassette) error {
+	return json.Unmarshal(data, cassette)
+}
+
+var JSONCassetteCodec CassetteCodec = jsonCassetteCodec{}
+
+// CassetteMatcher reports whether a recorded request matches the actual one.
+type CassetteMatcher func(recorded, actual *CassetteRequest) bool
+
+func MatchCassetteMethod(recorded, actual *CassetteRequest) bool {
+	return strings.EqualFold(recorded.Method, actual.Method)
+}
+
+func MatchCassetteURL(recorded, actual *CassetteRequest) bool {
+	return recorded.URL == actual.URL
+}
+
+func MatchCassetteBody(recorded, actual *CassetteRequest) bool {
+	return bytes.Equal(recorded.Body, actual.Body)
+}
+
+// MatchCassetteJSONBody compares bodies as JSON documents so key order and whitespace do not matter. Bodies
+// that are not valid JSON are compared byte by byte.
+func MatchCassetteJSONBody(recorded, actual *CassetteRequest) bool {
+	var recordedValue, actualValue interface{}
+	if json.Unmarshal(recorded.Body, &recordedValue) != nil || json.Unmarshal(actual.Body, &actualValue) != nil {
+		return bytes.Equal(recorded.Body, actual.Body)
+	}
+	return reflect.DeepEqual(recordedValue, actualValue)
+}
+
+// MatchCassetteHeaders compares all recorded headers except the ignored ones.
+func MatchCassetteHeaders(ignoredHeaders ...string) CassetteMatcher {
+	ignored := make(map[string]bool, len(ignoredHeaders))
+	for _, header := range ignoredHeaders {
+		ignored[http.CanonicalHeaderKey(header)] = true
+	}
+	return func(recorded, actual *CassetteRequest) bool {
+		for key, values := range recorded.Header {
+			if ignored[http.CanonicalHeaderKey(key)] {
+				continue
+			}
+			if !reflect.DeepEqual(values, actual.Header.Values(key)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+var defaultCassetteMatchers = []CassetteMatcher{MatchCassetteMethod, MatchCassetteURL, MatchCassetteBody}
+
+type CassetteOptions struct {
+	Path string
+	Mode CassetteMode
+	// Client sends the requests in record mode.
+	Client Client
+	// Matchers must all match for a recording to be replayed, method, URL and body are compared when empty.
+	Matchers []CassetteMatcher
+	Codec    CassetteCodec
+	// Redaction masks the headers of recorded requests and responses so credentials never reach the cassette
+	// file, DefaultRedactionRules by default. Replayed requests are masked the same way before being matched.
+	Redaction *RedactionRules
+}
+
+type cassettePlayer struct {
+	opts     CassetteOptions
+	redactor *redactor
+	mutex    *sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func newCassettePlayer(options *CassetteOptions) (*cassettePlayer, error) {
+	opts := *options
+	if opts.Codec == nil {
+		opts.Codec = JSONCassetteCodec
+	}
+	if len(opts.Matchers) == 0 {
+		opts.Matchers = defaultCassetteMatchers
+	}
+	player := &cassettePlayer{opts: opts, redactor: newRedactor(opts.Redaction), mutex: new(sync.Mutex), cassette: &Cassette{}}
+	switch opts.Mode {
+	case CassetteModeRecord:
+		if opts.Client == nil {
+			return nil, errors.New("record mode requires a client")
+		}
+	case CassetteModeReplay:
+		data, err := os.ReadFile(opts.Path)
+		if err != nil {
+			return nil, fmt.Errorf("read cassette: %w", err)
+		}
+		if err = opts.Codec.Unmarshal(data, player.cassette); err != nil {
+			return nil, fmt.Errorf("decode cassette: %w", err)
+		}
+		player.used = make([]bool, len(player.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %d", opts.Mode)
+	}
+	return player, nil
+}
+
+func toCassetteRequest(request *http.Request, redactor *redactor) (*CassetteRequest, error) {
+	body, err := snapshotRequestBody(request)
+	if err != nil {
+		return nil, err
+	}
+	return &CassetteRequest{
+		Method: request.Method,
+		URL:    request.URL.String(),
+		Header: redactor.redactHeader(request.Header),
+		Body:   body.Bytes(),
+	}, nil
+}
+
+func (p *cassettePlayer) handle(request *http.Request) (*Response, error) {
+	actual, err := toCassetteRequest(request, p.redactor)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.Mode == CassetteModeRecord {
+		return p.record(request, actual)
+	}
+	return p.replay(actual, isStreamResponseRequested(request.Context()))
+}
+
+func (p *cassettePlayer) record(request *http.Request, actual *CassetteRequest) (*Response, error) {
+	resp, err := p.opts.Client.Request(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Stream != nil {
+		// streamed responses are recorded in full and replayed as streams
+		body, readErr := io.ReadAll(resp.Stream)
+		resp.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		resp = &Response{Code: resp.Code, Header: resp.Header, URI: resp.URI, Stream: io.NopCloser(bytes.NewReader(body))}
+		p.append(actual, resp, body)
+		return resp, nil
+	}
+	p.append(actual, resp, resp.Body)
+	return resp, nil
+}
+
+func (p *cassettePlayer) append(actual *CassetteRequest, resp *Response, body []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.cassette.Interactions = append(p.cassette.Interactions, &CassetteInteraction{
+		Request: *actual,
+		Response: CassetteResponse{
+			Code:   resp.Code,
+			Header: p.redactor.redactHeader(resp.Header),
+			Body:   append(CassetteBody(nil), body...),
+			URI:    resp.URI,
+		},
+	})
+}
+
+// replay answers with the first unused recording that satisfies all matchers.
+func (p *cassettePlayer) replay(actual *CassetteRequest, stream bool) (*Response, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, interaction := range p.cassette.Interactions {
+		if p.used[i] || !p.matches(&interaction.Request, actual) {
+			continue
+		}
+		p.used[i] = true
+		recorded := interaction.Response
+		resp := &Response{Code: recorded.Code, Header: recorded.Header.Clone(), URI: recorded.URI}
+		if stream {
+			resp.Stream = io.NopCloser(bytes.NewReader(recorded.Body))
+		} else {
+			resp.Body = append([]byte(nil), recorded.Body...)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrCassetteUnmatched, actual.Method, actual.URL)
+}
+
+func (p *cassettePlayer) matches(recorded, actual *CassetteRequest) bool {
+	for _, matcher := range p.opts.Matchers {
+		if !matcher(recorded, actual) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *cassettePlayer) save() error {
+	p.mutex.Lock()
+	data, err := p.opts.Codec.Marshal(p.cassette)
+	p.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.opts.Path, data, 0o644)
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Server", "real")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(append([]byte("echo:"), body...))
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	real := NewBuilder().TimeoutSec(5).Build()
+	defer real.Stop()
+	recorder, err := NewCassetteMockHTTPClient(&CassetteOptions{Path: path, Mode: CassetteModeRecord, Client: real})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	req, _ := NewRequestBuilder().Method("POST").URL(ts.URL + "/items").
+		Header(NewHeaderMaker().Set("X-Request-Id", "1").Make()).StringBody(`{"a":1,"b":2}`).Build()
+	resp, err := recorder.Request(req)
+	if err != nil || resp.Code != http.StatusCreated {
+		t.Fatalf("record request failed: %v, %v", resp, err)
+	}
+	if err = recorder.SaveCassette(); err != nil {
+		t.Fatalf("failed to save cassette: %v", err)
+	}
+	// the server is gone, replay must not need it
+	url := ts.URL
+	ts.Close()
+
+	player, err := NewCassetteMockHTTPClient(&CassetteOptions{
+		Path:     path,
+		Mode:     CassetteModeReplay,
+		Matchers: []CassetteMatcher{MatchCassetteMethod, MatchCassetteURL, MatchCassetteJSONBody, MatchCassetteHeaders("X-Request-Id")},
+	})
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+	replayReq, _ := NewRequestBuilder().Method("POST").URL(url + "/items").
+		Header(NewHeaderMaker().Set("X-Request-Id", "2").Make()).StringBody(`{ "b": 2, "a": 1 }`).Build()
+	replayed, err := player.Request(replayReq)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if replayed.Code != http.StatusCreated || string(replayed.Body) != `echo:{"a":1,"b":2}` || replayed.Header.Get("X-Server") != "real" {
+		t.Errorf("unexpected replayed response %+v", replayed)
+	}
+
+	// every recording is replayed once
+	again, _ := NewRequestBuilder().Method("POST").URL(url + "/items").StringBody(`{"a":1,"b":2}`).Build()
+	if _, err = player.Request(again); !errors.Is(err, ErrCassetteUnmatched) {
+		t.Errorf("expected ErrCassetteUnmatched for exhausted recording, got %v", err)
+	}
+	unknown, _ := NewRequestBuilder().URL(url + "/unknown").Build()
+	if _, err = player.Request(unknown); !errors.Is(err, ErrCassetteUnmatched) {
+		t.Errorf("expected ErrCassetteUnmatched for unknown request, got %v", err)
+	}
+}
+
+func TestCassetteRedactsCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	real := NewBuilder().TimeoutSec(5).Build()
+	defer real.Stop()
+	recorder, err := NewCassetteMockHTTPClient(&CassetteOptions{Path: path, Mode: CassetteModeRecord, Client: real})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	header := NewHeaderMaker().Set("Authorization", "Bearer secret").Set("Cookie", "session=secret").Make()
+	req, _ := NewRequestBuilder().URL(ts.URL).Header(header).Build()
+	resp, err := recorder.Request(req)
+	if err != nil || resp.Header.Get("Set-Cookie") != "session=secret" {
+		t.Fatalf("record request failed or its response was redacted: %v, %v", resp, err)
+	}
+	if err = recorder.SaveCassette(); err != nil {
+		t.Fatalf("failed to save cassette: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") || !strings.Contains(string(data), DefaultRedactedValue) {
+		t.Errorf("credentials were recorded:\n%s", data)
+	}
+
+	player, err := NewCassetteMockHTTPClient(&CassetteOptions{
+		Path:     path,
+		Mode:     CassetteModeReplay,
+		Matchers: []CassetteMatcher{MatchCassetteURL, MatchCassetteHeaders()},
+	})
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+	header = NewHeaderMaker().Set("Authorization", "Bearer other").Set("Cookie", "session=other").Make()
+	replayReq, _ := NewRequestBuilder().URL(ts.URL).Header(header).Build()
+	if replayed, err := player.Request(replayReq); err != nil || string(replayed.Body) != "ok" {
+		t.Errorf("replay with masked headers failed: %v, %v", replayed, err)
+	}
+}
+
+func TestCassetteReplaysBinaryBodies(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte("gommon"))
+	writer.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// served as an archive, the transport would decode it as a Content-Encoding
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(compressed.Bytes())
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	real := NewBuilder().TimeoutSec(5).Build()
+	defer real.Stop()
+	recorder, err := NewCassetteMockHTTPClient(&CassetteOptions{Path: path, Mode: CassetteModeRecord, Client: real})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	upload := []byte{0x00, 0xff, 0xfe, 0x80, 'g'}
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL + "/archive").BytesBody(upload).Build()
+	if resp, err := recorder.Request(req); err != nil || !bytes.Equal(resp.Body, compressed.Bytes()) {
+		t.Fatalf("record request failed: %v, %v", resp, err)
+	}
+	if err = recorder.SaveCassette(); err != nil {
+		t.Fatalf("failed to save cassette: %v", err)
+	}
+	url := ts.URL
+	ts.Close()
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"base64"`) {
+		t.Errorf("expected binary bodies to be stored in base64:\n%s", data)
+	}
+
+	player, err := NewCassetteMockHTTPClient(&CassetteOptions{Path: path, Mode: CassetteModeReplay})
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+	replayReq, _ := NewRequestBuilder().Method(http.MethodPost).URL(url + "/archive").BytesBody(upload).Build()
+	replayed, err := player.Request(replayReq)
+	if err != nil || !bytes.Equal(replayed.Body, compressed.Bytes()) {
+		t.Fatalf("expected the recorded gzip body, got %v, %v", replayed, err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(replayed.Body))
+	if err != nil {
+		t.Fatalf("replayed body is not gzip: %v", err)
+	}
+	if data, _ := io.ReadAll(reader); string(data) != "gommon" {
+		t.Errorf("unexpected replayed content %q", data)
+	}
+}
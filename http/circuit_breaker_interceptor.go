@@ -0,0 +1,303 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = 0
+	CircuitOpen     CircuitState = 1
+	CircuitHalfOpen CircuitState = 2
+)
+
+const (
+	DefaultCircuitConsecutiveFailures = 5
+	DefaultCircuitMinRequests         = 10
+	DefaultCircuitWindow              = time.Minute
+	DefaultCircuitCoolDown            = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+type CircuitBreakerOptions struct {
+	// KeyFunc groups requests into independent circuits, CircuitKeyByHost is used when nil.
+	KeyFunc func(*Request) string
+	// FailureRatio trips the circuit when failures/requests within Window reaches it, 0 disables the check.
+	FailureRatio float64
+	// MinRequests is the number of requests within Window before FailureRatio is evaluated.
+	MinRequests int
+	// ConsecutiveFailures trips the circuit after that many failures in a row, 0 disables the check.
+	// When both thresholds are 0, DefaultCircuitConsecutiveFailures is used.
+	ConsecutiveFailures int
+	// Window is the period over which the failure ratio is counted while the circuit is closed.
+	Window time.Duration
+	// CoolDown is how long the circuit stays open before letting probe requests through.
+	CoolDown time.Duration
+	// HalfOpenMaxRequests is the number of probe requests allowed while half-open; the circuit closes once
+	// they all succeed and opens again on the first failure.
+	HalfOpenMaxRequests int
+	// IsFailure decides whether an outcome counts as a failure, by default transport errors and 5xx
+	// responses do while cancelled requests do not.
+	IsFailure func(*Response, error) bool
+	// OnStateChange is invoked after a circuit changes its state.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+func CircuitKeyByHost(request *Request) string {
+	return request.URL.Host
+}
+
+func CircuitKeyByRoute(request *Request) string {
+	return request.Method + " " + request.URL.Host + request.URL.Path
+}
+
+func defaultIsCircuitFailure(resp *Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp == nil || resp.Code >= 500
+}
+
+type circuit struct {
+	state               CircuitState
+	windowStart         time.Time
+	requests            int
+	failures            int
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    int
+	halfOpenSuccesses   int
+	// generation changes with every transition, outcomes of requests admitted in an earlier generation do not
+	// count toward the half-open probes
+	generation uint64
+}
+
+// circuitAdmission is the state and generation of a circuit when a request was let through.
+type circuitAdmission struct {
+	state      CircuitState
+	generation uint64
+}
+
+type circuitTransition struct {
+	key      string
+	from, to CircuitState
+}
+
+// CircuitBreaker tracks one circuit per key and rejects requests with ErrCircuitOpen while a circuit is open.
+// Closed circuits without requests for a Window are dropped, so keys with ids in them do not pile up.
+type CircuitBreaker struct {
+	opts      CircuitBreakerOptions
+	mutex     *sync.Mutex
+	circuits  map[string]*circuit
+	lastSweep time.Time
+}
+
+func NewCircuitBreaker(options *CircuitBreakerOptions) *CircuitBreaker {
+	if options == nil {
+		options = &CircuitBreakerOptions{}
+	}
+	opts := *options
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = CircuitKeyByHost
+	}
+	if opts.FailureRatio <= 0 && opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = DefaultCircuitConsecutiveFailures
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = DefaultCircuitMinRequests
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultCircuitWindow
+	}
+	if opts.CoolDown <= 0 {
+		opts.CoolDown = DefaultCircuitCoolDown
+	}
+	if opts.HalfOpenMaxRequests <= 0 {
+		opts.HalfOpenMaxRequests = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = defaultIsCircuitFailure
+	}
+	return &CircuitBreaker{
+		opts:     opts,
+		mutex:    new(sync.Mutex),
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func CircuitBreakerInterceptor(options *CircuitBreakerOptions) Interceptor {
+	return NewCircuitBreaker(options).Intercept
+}
+
+// State returns the current state of the circuit for key, CircuitClosed for unknown keys.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.opts.CoolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+func (b *CircuitBreaker) Intercept(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+	key := b.opts.KeyFunc(request)
+	admission, allowed := b.allow(key)
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+	}
+	resp, err := next(request)
+	b.record(key, admission, b.opts.IsFailure(resp, err))
+	return resp, err
+}
+
+func (b *CircuitBreaker) getCircuit(key string, now time.Time) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		b.sweep(now)
+		c = &circuit{state: CircuitClosed, windowStart: now}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+// sweep drops closed circuits whose window has expired, they would start over with fresh counts anyway. It
+// must be called with mutex held.
+func (b *CircuitBreaker) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.opts.Window {
+		return
+	}
+	b.lastSweep = now
+	for key, c := range b.circuits {
+		if c.state == CircuitClosed && now.Sub(c.windowStart) > b.opts.Window {
+			delete(b.circuits, key)
+		}
+	}
+}
+
+func (b *CircuitBreaker) allow(key string) (admission circuitAdmission, allowed bool) {
+	var transition *circuitTransition
+	defer func() {
+		b.notify(transition)
+	}()
+
+	now := time.Now()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c := b.getCircuit(key, now)
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.windowStart) > b.opts.Window {
+			c.resetCounts(now)
+		}
+		return circuitAdmission{CircuitClosed, c.generation}, true
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < b.opts.CoolDown {
+			return circuitAdmission{CircuitOpen, c.generation}, false
+		}
+		transition = b.transit(key, c, CircuitHalfOpen, now)
+	}
+	if c.halfOpenInFlight >= b.opts.HalfOpenMaxRequests {
+		return circuitAdmission{CircuitHalfOpen, c.generation}, false
+	}
+	c.halfOpenInFlight++
+	return circuitAdmission{CircuitHalfOpen, c.generation}, true
+}
+
+func (b *CircuitBreaker) record(key string, admission circuitAdmission, failed bool) {
+	var transition *circuitTransition
+	defer func() {
+		b.notify(transition)
+	}()
+
+	now := time.Now()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c := b.getCircuit(key, now)
+	switch c.state {
+	case CircuitClosed:
+		c.requests++
+		if !failed {
+			c.consecutiveFailures = 0
+			return
+		}
+		c.failures++
+		c.consecutiveFailures++
+		if b.shouldTrip(c) {
+			transition = b.transit(key, c, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if admission.state != CircuitHalfOpen || admission.generation != c.generation {
+			// outcome of a request admitted before the circuit opened, or a probe of an earlier half-open period
+			return
+		}
+		c.halfOpenInFlight--
+		if failed {
+			transition = b.transit(key, c, CircuitOpen, now)
+			return
+		}
+		c.halfOpenSuccesses++
+		if c.halfOpenSuccesses >= b.opts.HalfOpenMaxRequests {
+			transition = b.transit(key, c, CircuitClosed, now)
+		}
+	}
+}
+
+func (b *CircuitBreaker) shouldTrip(c *circuit) bool {
+	if b.opts.ConsecutiveFailures > 0 && c.consecutiveFailures >= b.opts.ConsecutiveFailures {
+		return true
+	}
+	return b.opts.FailureRatio > 0 && c.requests >= b.opts.MinRequests &&
+		float64(c.failures)/float64(c.requests) >= b.opts.FailureRatio
+}
+
+// transit must be called with mutex held.
+func (b *CircuitBreaker) transit(key string, c *circuit, to CircuitState, now time.Time) *circuitTransition {
+	from := c.state
+	c.state = to
+	c.generation++
+	c.halfOpenInFlight = 0
+	c.halfOpenSuccesses = 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = now
+	case CircuitClosed:
+		c.resetCounts(now)
+	}
+	return &circuitTransition{key, from, to}
+}
+
+func (c *circuit) resetCounts(now time.Time) {
+	c.windowStart = now
+	c.requests = 0
+	c.failures = 0
+	c.consecutiveFailures = 0
+}
+
+func (b *CircuitBreaker) notify(transition *circuitTransition) {
+	if transition != nil && b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(transition.key, transition.from, transition.to)
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	var healthy atomic.Bool
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	var mutex sync.Mutex
+	var transitions []string
+	breaker := NewCircuitBreaker(&CircuitBreakerOptions{
+		ConsecutiveFailures: 2,
+		CoolDown:            50 * time.Millisecond,
+		OnStateChange: func(key string, from, to CircuitState) {
+			mutex.Lock()
+			transitions = append(transitions, from.String()+"->"+to.String())
+			mutex.Unlock()
+		},
+	})
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).AddInterceptor(breaker.Intercept).Build()
+	defer c.Stop()
+
+	doRequest := func() (*Response, error) {
+		req, _ := NewRequestBuilder().URL(ts.URL).Build()
+		return c.Request(req)
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp, err := doRequest(); err != nil || resp.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500 response, got %v, %v", resp, err)
+		}
+	}
+	if _, err := doRequest(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected open circuit to short-circuit requests, server got %d hits", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if state := breaker.State(CircuitKeyByHost(mustParseRequest(t, ts.URL))); state != CircuitHalfOpen {
+		t.Errorf("expected half-open after cool-down, got %s", state)
+	}
+	healthy.Store(true)
+	if resp, err := doRequest(); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected probe request to succeed, got %v, %v", resp, err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	expected := []string{"Closed->Open", "Open->HalfOpen", "HalfOpen->Closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("expected transitions %v, got %v", expected, transitions)
+		}
+	}
+}
+
+func TestCircuitBreakerFailureRatioPerRoute(t *testing.T) {
+	breaker := NewCircuitBreaker(&CircuitBreakerOptions{
+		KeyFunc:      CircuitKeyByRoute,
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		CoolDown:     time.Minute,
+	})
+	failing := func(*Request) (*Response, error) { return &Response{Code: http.StatusBadGateway}, nil }
+	succeeding := func(*Request) (*Response, error) { return &Response{Code: http.StatusOK}, nil }
+
+	bad := mustParseRequest(t, "http://example.com/bad")
+	good := mustParseRequest(t, "http://example.com/good")
+	for _, next := range []func(*Request) (*Response, error){succeeding, failing, succeeding, failing} {
+		if _, err := breaker.Intercept(bad, next); err != nil {
+			t.Fatalf("unexpected error before the ratio is reached: %v", err)
+		}
+	}
+	if _, err := breaker.Intercept(bad, succeeding); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected circuit for /bad to be open, got %v", err)
+	}
+	if _, err := breaker.Intercept(good, succeeding); err != nil {
+		t.Errorf("expected circuit for /good to stay closed, got %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+	breaker := NewCircuitBreaker(&CircuitBreakerOptions{ConsecutiveFailures: 1, CoolDown: 10 * time.Millisecond})
+	req := mustParseRequest(t, "http://example.com")
+	failing := func(*Request) (*Response, error) { return nil, errors.New("connection refused") }
+
+	_, _ = breaker.Intercept(req, failing)
+	time.Sleep(20 * time.Millisecond)
+	_, _ = breaker.Intercept(req, failing)
+	if state := breaker.State("example.com"); state != CircuitOpen {
+		t.Errorf("expected failed probe to reopen the circuit, got %s", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresStaleProbes(t *testing.T) {
+	breaker := NewCircuitBreaker(&CircuitBreakerOptions{ConsecutiveFailures: 1, CoolDown: 10 * time.Millisecond, HalfOpenMaxRequests: 2})
+	key := "example.com"
+	open := func() {
+		admission, _ := breaker.allow(key)
+		breaker.record(key, admission, true)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	open()
+	stale, ok := breaker.allow(key)
+	if !ok || stale.state != CircuitHalfOpen {
+		t.Fatalf("expected a half-open probe, got %+v", stale)
+	}
+	failed, _ := breaker.allow(key)
+	breaker.record(key, failed, true)
+	time.Sleep(20 * time.Millisecond)
+
+	// a new half-open period admits 2 probes, the outcome of the stale probe must not free a slot
+	for i := 0; i < 2; i++ {
+		if _, ok := breaker.allow(key); !ok {
+			t.Fatalf("expected probe %d to be admitted", i)
+		}
+	}
+	breaker.record(key, stale, false)
+	if _, ok := breaker.allow(key); ok {
+		t.Errorf("expected no more than 2 probes in flight")
+	}
+}
+
+func TestCircuitBreakerDropsIdleCircuits(t *testing.T) {
+	breaker := NewCircuitBreaker(&CircuitBreakerOptions{KeyFunc: CircuitKeyByRoute, Window: 10 * time.Millisecond})
+	succeeding := func(*Request) (*Response, error) { return &Response{Code: http.StatusOK}, nil }
+	for i := 0; i < 10; i++ {
+		breaker.Intercept(mustParseRequest(t, fmt.Sprintf("http://example.com/users/%d", i)), succeeding)
+	}
+	time.Sleep(20 * time.Millisecond)
+	breaker.Intercept(mustParseRequest(t, "http://example.com/users/new"), succeeding)
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if n := len(breaker.circuits); n != 1 {
+		t.Errorf("expected idle circuits to be dropped, %d left", n)
+	}
+}
+
+func mustParseRequest(t *testing.T, url string) *Request {
+	req, err := NewRequestBuilder().URL(url).Build()
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	return req
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,15 +23,20 @@ type httpClient struct {
 	cancelFunc          func()
 	id                  string
 	interceptors        []Interceptor
-	queue               chan TrackableRequest
+	defaultLane         *lane
+	lanes               map[string]*lane
+	laneKeyFunc         func(request *http.Request) string
 	logger              logging.Logger
 	status              int
 	rwMutex             *sync.RWMutex
-	workerSize          int
 	numWorkers          int32
 	baseClient          *http.Client
 	stopWg              *sync.WaitGroup
 	maxResponseBodySize int64
+	// compression is nil unless the client compresses requests or decompresses responses
+	compression *compression
+	// connectionTimings attaches httptrace timings to responses
+	connectionTimings bool
 }
 
 // deprecated
@@ -91,11 +97,10 @@ func New(id string, maxConcurrentRequests, maxQueueSize, timeoutInSec int) Clien
 		cancelFunc:   cancelFunc,
 		id:           id,
 		interceptors: []Interceptor{},
-		queue:        make(chan TrackableRequest, maxQueueSize),
+		defaultLane:  newLane(DefaultLaneKey, maxConcurrentRequests, maxQueueSize),
 		logger:       logging.GlobalLogger.WithPrefix("http-" + id).WithWaterMark(logging.FATAL),
 		status:       PoolStatusRunning,
 		rwMutex:      new(sync.RWMutex),
-		workerSize:   maxConcurrentRequests,
 		numWorkers:   0,
 		baseClient:   newHTTPClient(timeoutInSec),
 		stopWg:       stopWg,
@@ -105,14 +110,32 @@ func New(id string, maxConcurrentRequests, maxQueueSize, timeoutInSec int) Clien
 }
 
 func (c *httpClient) startWorkers() {
-	for i := 0; i < c.workerSize; i++ {
+	c.startLaneWorkers(c.defaultLane, "")
+	for _, l := range c.lanes {
+		c.startLaneWorkers(l, fmt.Sprintf("[%s]", l.key))
+	}
+}
+
+func (c *httpClient) startLaneWorkers(l *lane, prefix string) {
+	for i := 0; i < l.workerSize; i++ {
 		c.stopWg.Add(1)
-		workerLogger := c.logger.WithPrefix(fmt.Sprintf("[Worker-%d]", i+1))
-		go c.workerRoutine(i+1, workerLogger)
+		workerLogger := c.logger.WithPrefix(fmt.Sprintf("%s[Worker-%d]", prefix, i+1))
+		go c.workerRoutine(l, workerLogger)
 		atomic.AddInt32(&c.numWorkers, 1)
 	}
 }
 
+// laneFor picks the lane of a request, the default lane serves keys without a lane of their own.
+func (c *httpClient) laneFor(request *http.Request) *lane {
+	if len(c.lanes) == 0 {
+		return c.defaultLane
+	}
+	if l, ok := c.lanes[c.laneKeyFunc(request)]; ok {
+		return l
+	}
+	return c.defaultLane
+}
+
 func (c *httpClient) decrementWorkerCount() {
 	atomic.AddInt32(&c.numWorkers, -1)
 }
@@ -121,29 +144,31 @@ func (c *httpClient) workerCount() int {
 	return int(atomic.LoadInt32(&c.numWorkers))
 }
 
-func (c *httpClient) workerRoutine(id int, logger logging.Logger) {
+func (c *httpClient) workerRoutine(l *lane, logger logging.Logger) {
 	defer c.completeWorker()
 	logger.Debugf(c.ctx, "worker has started.")
 	for {
 		select {
-		case req := <-c.queue:
-			request := req.(*trackableRequest)
-			c.executeRequest(request, logger)
+		case <-l.queue.ready:
+			if request := l.queue.poll(); request != nil {
+				c.executeLaneRequest(l, request, logger)
+			}
 		case <-c.ctx.Done():
 			logger.Debugf(c.ctx, "worker is exiting because client context is done; draining remaining queue.")
-			for {
-				select {
-				case req := <-c.queue:
-					request := req.(*trackableRequest)
-					c.executeRequest(request, logger)
-				default:
-					return
-				}
+			for request := l.queue.poll(); request != nil; request = l.queue.poll() {
+				c.executeLaneRequest(l, request, logger)
 			}
+			return
 		}
 	}
 }
 
+func (c *httpClient) executeLaneRequest(l *lane, request *trackableRequest, logger logging.Logger) {
+	atomic.AddInt32(&l.inFlight, 1)
+	defer atomic.AddInt32(&l.inFlight, -1)
+	c.executeRequest(request, logger)
+}
+
 func (c *httpClient) completeWorker() {
 	if recovered := recover(); recovered != nil {
 		c.logger.Errorf(c.ctx, "worker has crashed with error: %v", recovered)
@@ -152,10 +177,23 @@ func (c *httpClient) completeWorker() {
 	c.stopWg.Done()
 }
 
+// executeRequest runs the request on the calling worker. Streamed responses release the worker as soon as the
+// headers arrive, the request itself is completed when the caller closes the stream.
 func (c *httpClient) executeRequest(request *trackableRequest, logger logging.Logger) (success bool) {
-	defer request.complete()
+	streamed := false
+	defer func() {
+		if !streamed {
+			request.complete()
+		}
+	}()
 	logger.Debugf(c.ctx, "worker has acquired request(%s).", request.id)
-	resp, err := intercept(c.interceptors, request.getRequest(), func(req *Request) (*Response, error) {
+	if err := request.getRequest().Context().Err(); err != nil {
+		logger.Debugf(c.ctx, "request(%s) is dropped because its context is done: %v", request.id, err)
+		request.response.reject(err)
+		return false
+	}
+	stream := isStreamResponseRequested(request.getRequest().Context())
+	resp, err := c.runRequest(request, func(req *Request) (*Response, error) {
 		// Reset body from GetBody so retries and interceptor chains see a fresh body each time.
 		if req.GetBody != nil {
 			body, bodyErr := req.GetBody()
@@ -164,22 +202,46 @@ func (c *httpClient) executeRequest(request *trackableRequest, logger logging.Lo
 			}
 			req.Body = body
 		}
+		var timer *connectionTimer
+		if c.connectionTimings {
+			timer = newConnectionTimer()
+			req = req.WithContext(timer.withTrace(req.Context()))
+		}
+		countAttempt(req.Context())
 		rawResponse, err := c.baseClient.Do(req)
 		if err != nil {
 			logger.Debugf(c.ctx, "request(%s) failed: %v", request.id, err)
 			return nil, err
 		}
-		response, err := fromRawResponse(rawResponse, c.maxResponseBodySize)
-		if err != nil {
+		if c.compression != nil {
+			if err := c.compression.decompressResponse(rawResponse); err != nil {
+				rawResponse.Body.Close()
+				logger.Debugf(c.ctx, "request(%s) unable to decompress response body: %v", request.id, err)
+				return nil, err
+			}
+		}
+		var response *Response
+		if stream {
+			response = fromRawStreamResponse(rawResponse, c.maxResponseBodySize)
+		} else if response, err = fromRawResponse(rawResponse, c.maxResponseBodySize); err != nil {
 			logger.Debugf(c.ctx, "request(%s) unable to parse response body: %v", request.id, err)
 			return nil, err
 		}
+		if timer != nil {
+			response.Timings = timer.timings()
+			logger.Debugf(c.ctx, "request(%s) timings: %s", request.id, response.Timings)
+		}
 		return response, nil
 	})
 	if err != nil {
 		request.response.reject(err)
 		success = false
 	} else {
+		streamed = resp != nil && resp.Stream != nil
+		if streamed {
+			// only the stream handed to the caller completes the request, interceptors close the others
+			resp.Stream = &completingStream{ReadCloser: resp.Stream, onClose: request.complete}
+		}
 		request.response.resolve(resp)
 		success = true
 		logger.Debugf(c.ctx, "request(%s) has been resolved with code %d.", request.id, resp.Code)
@@ -187,6 +249,24 @@ func (c *httpClient) executeRequest(request *trackableRequest, logger logging.Lo
 	return
 }
 
+// runRequest sends the request through the interceptors, unless it was submitted with its own executor. The
+// body is compressed first, so interceptors sign and dump the body that goes on the wire.
+func (c *httpClient) runRequest(request *trackableRequest, requestExecutor func(*Request) (*Response, error)) (*Response, error) {
+	req := request.getRequest()
+	req = req.WithContext(context.WithValue(req.Context(), queueWaitKey{}, time.Since(request.enqueuedAt)))
+	if request.execute != nil {
+		return request.execute(req)
+	}
+	if c.compression != nil {
+		prepared, err := c.compression.prepareRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		req = prepared
+	}
+	return intercept(c.interceptors, req, requestExecutor)
+}
+
 func (c *httpClient) Stop() {
 	c.rwMutex.Lock()
 	if c.status != PoolStatusRunning {
@@ -220,7 +300,30 @@ func (c *httpClient) Status() int {
 }
 
 func (c *httpClient) request(request *http.Request) *awaitableResponse {
+	return c.submit(request, nil)
+}
+
+// submit queues the request for the workers, a non-nil execute replaces the interceptor chain. The request
+// context carries submit so interceptors can dispatch extra attempts through the queue(see requestSubmitter).
+func (c *httpClient) submit(request *http.Request, execute func(*Request) (*Response, error)) *awaitableResponse {
 	tRequest := newTrackableRequest(request)
+	tRequest.execute = execute
+	queue := c.laneFor(request).queue
+	ctx := context.WithValue(tRequest.getRequest().Context(), requestSubmitterKey{}, requestSubmitter(c.submitAsync))
+	tRequest.request = tRequest.getRequest().WithContext(ctx)
+	if err := ctx.Err(); err != nil {
+		tRequest.response.reject(err)
+		tRequest.complete()
+		return tRequest.response
+	}
+	// drop the request from the queue as soon as its context is done so it neither holds a queue slot nor
+	// keeps the caller waiting
+	tRequest.stopCtxWatch = context.AfterFunc(ctx, func() {
+		if queue.remove(tRequest) {
+			tRequest.response.reject(ctx.Err())
+			tRequest.complete()
+		}
+	})
 	c.rwMutex.Lock()
 	if c.status != PoolStatusRunning {
 		c.rwMutex.Unlock()
@@ -228,10 +331,10 @@ func (c *httpClient) request(request *http.Request) *awaitableResponse {
 		tRequest.complete()
 		return tRequest.response
 	}
-	select {
-	case c.queue <- tRequest:
+	tRequest.enqueuedAt = time.Now()
+	if queue.offer(tRequest) {
 		c.rwMutex.Unlock()
-	default:
+	} else {
 		c.rwMutex.Unlock()
 		tRequest.response.reject(errors.Error("request queue is full"))
 		tRequest.complete()
@@ -251,6 +354,25 @@ func (c *httpClient) RequestAsync(request *http.Request) AwaitableResponse {
 	return c.request(request)
 }
 
+// Stats reports the queue depth and in-flight requests of each lane.
+func (c *httpClient) Stats() ClientStats {
+	stats := ClientStats{Lanes: make([]LaneStats, 0, len(c.lanes)+1)}
+	stats.Lanes = append(stats.Lanes, c.defaultLane.stats())
+	keys := make([]string, 0, len(c.lanes))
+	for key := range c.lanes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		stats.Lanes = append(stats.Lanes, c.lanes[key].stats())
+	}
+	return stats
+}
+
+func (c *httpClient) submitAsync(request *http.Request, execute func(*Request) (*Response, error)) AwaitableResponse {
+	return c.submit(request, execute)
+}
+
 func (c *httpClient) Verbose(use bool) {
 	if !use {
 		c.logger.SetWaterMark(logging.FATAL)
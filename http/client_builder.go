@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +21,22 @@ type HTTPClientBuilder interface {
 	MaxQueueSize(n int) HTTPClientBuilder
 	MaxConnsPerHost(n int) HTTPClientBuilder
 	MaxResponseBodySize(n int64) HTTPClientBuilder
+	// Lane gives requests whose lane key is key their own workers and queue, see LaneKeyFunc. Nil options are
+	// the zero LaneOptions.
+	Lane(key string, options *LaneOptions) HTTPClientBuilder
+	// LaneKeyFunc maps requests to lane keys, LaneKeyByHost by default.
+	LaneKeyFunc(keyFunc func(request *http.Request) string) HTTPClientBuilder
+	// CompressRequests compresses request bodies of at least minSize bytes with encoding and sets their
+	// Content-Encoding. Bodies are buffered and compressed before the interceptors run, requests fail with
+	// ErrUnknownContentEncoding when encoding has no ContentCodec.
+	CompressRequests(encoding string, minSize int64) HTTPClientBuilder
+	// DecompressResponses decodes response bodies of any encoding with a codec and advertises them in
+	// Accept-Encoding, MaxResponseBodySize then limits the decompressed size.
+	DecompressResponses(decompress bool) HTTPClientBuilder
+	// ContentCodec adds or replaces the codec of an encoding, gzip, deflate and zstd are built in.
+	ContentCodec(codec ContentCodec) HTTPClientBuilder
+	// ConnectionTimings records DNS, connect, TLS and server timings of every attempt in Response.Timings.
+	ConnectionTimings(enable bool) HTTPClientBuilder
 	Build() Client
 }
 
@@ -34,6 +51,13 @@ type httpClientBuilder struct {
 	maxConnsPerHost       int
 	maxResponseBodySize   int64
 	transport             *http.Transport
+	lanes                 map[string]LaneOptions
+	laneKeyFunc           func(request *http.Request) string
+	requestEncoding       string
+	compressMinSize       int64
+	decompressResponses   bool
+	codecs                map[string]ContentCodec
+	connectionTimings     bool
 }
 
 func (h *httpClientBuilder) Id(id string) HTTPClientBuilder {
@@ -88,6 +112,44 @@ func (h *httpClientBuilder) MaxResponseBodySize(n int64) HTTPClientBuilder {
 	return h
 }
 
+func (h *httpClientBuilder) Lane(key string, options *LaneOptions) HTTPClientBuilder {
+	if h.lanes == nil {
+		h.lanes = make(map[string]LaneOptions)
+	}
+	laneOptions := LaneOptions{}
+	if options != nil {
+		laneOptions = *options
+	}
+	h.lanes[key] = laneOptions
+	return h
+}
+
+func (h *httpClientBuilder) LaneKeyFunc(keyFunc func(request *http.Request) string) HTTPClientBuilder {
+	h.laneKeyFunc = keyFunc
+	return h
+}
+
+func (h *httpClientBuilder) CompressRequests(encoding string, minSize int64) HTTPClientBuilder {
+	h.requestEncoding = encoding
+	h.compressMinSize = minSize
+	return h
+}
+
+func (h *httpClientBuilder) DecompressResponses(decompress bool) HTTPClientBuilder {
+	h.decompressResponses = decompress
+	return h
+}
+
+func (h *httpClientBuilder) ContentCodec(codec ContentCodec) HTTPClientBuilder {
+	h.codecs[strings.ToLower(codec.Encoding())] = codec
+	return h
+}
+
+func (h *httpClientBuilder) ConnectionTimings(enable bool) HTTPClientBuilder {
+	h.connectionTimings = enable
+	return h
+}
+
 func (h *httpClientBuilder) Build() Client {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
@@ -100,6 +162,19 @@ func (h *httpClientBuilder) Build() Client {
 	transport.MaxIdleConnsPerHost = numMaxConnsPerHost
 	transport.MaxIdleConns = numMaxConnsPerHost
 
+	lanes := make(map[string]*lane, len(h.lanes))
+	for key, options := range h.lanes {
+		lanes[key] = newLane(
+			key,
+			numWithinRange(options.MaxConcurrentRequests, 1, runtime.NumCPU()*32),
+			numWithinRange(options.MaxQueueSize, 1, runtime.NumCPU()*64),
+		)
+	}
+	laneKeyFunc := h.laneKeyFunc
+	if laneKeyFunc == nil {
+		laneKeyFunc = LaneKeyByHost
+	}
+
 	baseClient := &http.Client{
 		Timeout:   h.timeout,
 		Transport: transport,
@@ -110,20 +185,35 @@ func (h *httpClientBuilder) Build() Client {
 		logger = logging.GlobalLogger.WithPrefix(h.id)
 	}
 
+	var clientCompression *compression
+	if h.requestEncoding != "" || h.decompressResponses {
+		codecs := make(map[string]ContentCodec, len(h.codecs))
+		for encoding, codec := range h.codecs {
+			codecs[encoding] = codec
+		}
+		clientCompression = newCompression(codecs, h.requestEncoding, h.compressMinSize, h.decompressResponses)
+		if clientCompression.err != nil {
+			logger.Errorf(ctx, "every request will fail: %v", clientCompression.err)
+		}
+	}
+
 	client := &httpClient{
 		ctx:                 ctx,
 		cancelFunc:          cancelFunc,
 		id:                  h.id,
 		interceptors:        append([]Interceptor(nil), h.interceptors...),
-		queue:               make(chan TrackableRequest, queueSize),
+		defaultLane:         newLane(DefaultLaneKey, workerSize, queueSize),
+		lanes:               lanes,
+		laneKeyFunc:         laneKeyFunc,
 		logger:              logger,
 		status:              PoolStatusRunning,
 		rwMutex:             new(sync.RWMutex),
-		workerSize:          workerSize,
 		numWorkers:          0,
 		baseClient:          baseClient,
 		stopWg:              new(sync.WaitGroup),
 		maxResponseBodySize: h.maxResponseBodySize,
+		compression:         clientCompression,
+		connectionTimings:   h.connectionTimings,
 	}
 	client.startWorkers()
 	return client
@@ -150,5 +240,6 @@ func NewBuilder() HTTPClientBuilder {
 		maxConnsPerHost:       100,
 		maxResponseBodySize:   0,
 		transport:             transport,
+		codecs:                defaultContentCodecs(),
 	}
 }
@@ -176,3 +176,154 @@ func TestClientDrainsQueueOnStop(t *testing.T) {
 		}
 	}
 }
+
+func TestClientDropsQueuedRequestWhenContextIsCancelled(t *testing.T) {
+	blocked := make(chan struct{})
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-blocked
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := NewHTTPClient(1, 1, 30)
+	defer c.Stop()
+
+	// Occupies the only worker.
+	req1, _ := http.NewRequestWithContext(context.Background(), "GET", ts.URL, nil)
+	ar1 := c.RequestAsync(req1)
+	time.Sleep(100 * time.Millisecond)
+
+	// Waits in the only queue slot until its context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	req2, _ := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	ar2 := c.RequestAsync(req2)
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ar2.Get()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected cancelled request to fail while the worker is still busy")
+	}
+
+	// The slot is free again, so another request can be queued.
+	req3, _ := http.NewRequestWithContext(context.Background(), "GET", ts.URL, nil)
+	ar3 := c.RequestAsync(req3)
+
+	close(blocked)
+	if _, err := ar1.Get(); err != nil {
+		t.Fatalf("request 1 failed: %v", err)
+	}
+	if _, err := ar3.Get(); err != nil {
+		t.Fatalf("request 3 failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected the cancelled request never to reach the server, got %d hits", n)
+	}
+}
+
+func TestClientQueueWaitCountsTowardTimeout(t *testing.T) {
+	blocked := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := NewHTTPClient(1, 1, 30)
+	defer c.Stop()
+	defer close(blocked)
+
+	req1, _ := http.NewRequestWithContext(context.Background(), "GET", ts.URL, nil)
+	c.RequestAsync(req1)
+	time.Sleep(100 * time.Millisecond)
+
+	req2, err := NewRequestBuilder().URL(ts.URL).Timeout(50 * time.Millisecond).Build()
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	start := time.Now()
+	_, err = c.Request(req2)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded while queued, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected queued request to time out promptly, took %v", elapsed)
+	}
+}
+
+func TestClientLanesIsolateSlowHosts(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	slowReq, _ := NewRequestBuilder().URL(slow.URL).Build()
+	slowHost := slowReq.URL.Host
+	c := NewBuilder().MaxConcurrentRequests(1).MaxQueueSize(1).TimeoutSec(5).
+		Lane(slowHost, &LaneOptions{MaxConcurrentRequests: 1, MaxQueueSize: 2}).Build()
+	defer c.Stop()
+	defer close(release)
+
+	pending := make([]AwaitableResponse, 0, 3)
+	for i := 0; i < 3; i++ {
+		req, _ := NewRequestBuilder().URL(slow.URL).Build()
+		pending = append(pending, c.RequestAsync(req))
+		for i == 0 {
+			// wait for the lane worker to pick up the first request
+			if lane, _ := c.(StatsProvider).Stats().Lane(slowHost); lane.InFlight == 1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	req, _ := NewRequestBuilder().URL(slow.URL).Build()
+	if _, err := c.Request(req); err == nil {
+		t.Errorf("expected the slow lane queue to be full")
+	}
+
+	req, _ = NewRequestBuilder().URL(fast.URL).Build()
+	if resp, err := c.Request(req); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected the default lane to serve other hosts, got %v, %v", resp, err)
+	}
+
+	stats := c.(StatsProvider).Stats()
+	if len(stats.Lanes) != 2 || stats.Lanes[0].Key != DefaultLaneKey {
+		t.Fatalf("unexpected lanes %+v", stats.Lanes)
+	}
+	slowLane, ok := stats.Lane(slowHost)
+	if !ok || slowLane.InFlight != 1 || slowLane.QueueDepth != 2 || slowLane.MaxQueueSize != 2 || slowLane.MaxConcurrentRequests != 1 {
+		t.Errorf("unexpected slow lane stats %+v", slowLane)
+	}
+	if stats.Lanes[0].QueueDepth != 0 || stats.Lanes[0].InFlight != 0 {
+		t.Errorf("unexpected default lane stats %+v", stats.Lanes[0])
+	}
+
+	release <- struct{}{}
+	if _, err := pending[0].Get(); err != nil {
+		t.Errorf("expected the released request to succeed, got %v", err)
+	}
+}
+
+func TestClientLaneDefaultsNilOptions(t *testing.T) {
+	c := NewBuilder().Lane("nil-options", nil).Build()
+	defer c.Stop()
+	lane, ok := c.(StatsProvider).Stats().Lane("nil-options")
+	if !ok || lane.MaxConcurrentRequests != 1 || lane.MaxQueueSize != 1 {
+		t.Errorf("unexpected lane stats %+v", lane)
+	}
+}
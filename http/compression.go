@@ -0,0 +1,221 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dlshle/gommon/zstd"
+)
+
+const (
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderAcceptEncoding  = "Accept-Encoding"
+
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+)
+
+// ErrUnknownContentEncoding fails every request of a client whose CompressRequests encoding has no ContentCodec.
+var ErrUnknownContentEncoding = errors.New("no codec for content encoding")
+
+// ContentCodec compresses and decompresses one content encoding. Gzip, deflate and zstd are built in, other
+// encodings such as br can be plugged in with HTTPClientBuilder.ContentCodec.
+type ContentCodec interface {
+	// Encoding is the Content-Encoding token of the codec.
+	Encoding() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Encoding() string {
+	return EncodingGzip
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// deflateCodec implements the deflate content encoding, which is zlib framed deflate data.
+type deflateCodec struct{}
+
+func (deflateCodec) Encoding() string {
+	return EncodingDeflate
+}
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Encoding() string {
+	return EncodingZstd
+}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w), nil
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zstd.NewReader(r), nil
+}
+
+func defaultContentCodecs() map[string]ContentCodec {
+	return map[string]ContentCodec{
+		EncodingGzip:    gzipCodec{},
+		EncodingDeflate: deflateCodec{},
+		EncodingZstd:    zstdCodec{},
+	}
+}
+
+// compression holds the compression settings of a client.
+type compression struct {
+	codecs map[string]ContentCodec
+	// requestCodec compresses request bodies of at least minSize bytes, nil disables request compression
+	requestCodec ContentCodec
+	minSize      int64
+	decompress   bool
+	// acceptEncoding is advertised on requests without an Accept-Encoding header when decompress is set
+	acceptEncoding string
+	// err fails every request when the request encoding has no codec
+	err error
+}
+
+func newCompression(codecs map[string]ContentCodec, requestEncoding string, minSize int64, decompress bool) *compression {
+	c := &compression{codecs: codecs, minSize: minSize, decompress: decompress}
+	if requestEncoding != "" {
+		codec, ok := codecs[strings.ToLower(requestEncoding)]
+		if !ok {
+			c.err = fmt.Errorf("%w %s", ErrUnknownContentEncoding, requestEncoding)
+		}
+		c.requestCodec = codec
+	}
+	encodings := make([]string, 0, len(codecs))
+	for encoding := range codecs {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+	c.acceptEncoding = strings.Join(encodings, ", ")
+	return c
+}
+
+// prepareRequest compresses the body of request and advertises the decodable encodings, request is not mutated.
+// Bodies are buffered to be compressed, those of unknown length are compressed once read if they are large enough.
+func (c *compression) prepareRequest(request *Request) (*Request, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	compress := c.requestCodec != nil && request.Body != nil && request.Body != http.NoBody &&
+		request.Header.Get(HeaderContentEncoding) == "" && (request.ContentLength <= 0 || request.ContentLength >= c.minSize)
+	advertise := c.decompress && request.Header.Get(HeaderAcceptEncoding) == ""
+	if !compress && !advertise {
+		return request, nil
+	}
+	prepared := request.WithContext(request.Context())
+	prepared.Header = request.Header.Clone()
+	if prepared.Header == nil {
+		prepared.Header = make(http.Header)
+	}
+	if advertise {
+		prepared.Header.Set(HeaderAcceptEncoding, c.acceptEncoding)
+	}
+	if !compress {
+		return prepared, nil
+	}
+	data, err := io.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	if int64(len(data)) >= c.minSize {
+		var buffer bytes.Buffer
+		writer, err := c.requestCodec.NewWriter(&buffer)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = writer.Write(data); err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("compress request body: %w", err)
+		}
+		data = buffer.Bytes()
+		prepared.Header.Set(HeaderContentEncoding, c.requestCodec.Encoding())
+	}
+	prepared.Body = io.NopCloser(bytes.NewReader(data))
+	prepared.ContentLength = int64(len(data))
+	// the transport replays bodies through GetBody, which must return the body that was prepared
+	prepared.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return prepared, nil
+}
+
+// decompressResponse decodes the body of resp in place when all of its content encodings have a codec, so body
+// size limits apply to the decompressed body.
+func (c *compression) decompressResponse(resp *http.Response) error {
+	if !c.decompress || resp.Body == http.NoBody {
+		return nil
+	}
+	value := resp.Header.Get(HeaderContentEncoding)
+	if value == "" {
+		return nil
+	}
+	var codecs []ContentCodec
+	for _, encoding := range strings.Split(value, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding == "" || encoding == "identity" {
+			continue
+		}
+		codec, ok := c.codecs[encoding]
+		if !ok {
+			return nil
+		}
+		codecs = append(codecs, codec)
+	}
+	body := resp.Body
+	reader := io.ReadCloser(body)
+	// encodings are listed in the order they were applied
+	for i := len(codecs) - 1; i >= 0; i-- {
+		decoded, err := codecs[i].NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("decode %s response body: %w", codecs[i].Encoding(), err)
+		}
+		reader = decoded
+	}
+	resp.Body = &decodedBody{ReadCloser: reader, body: body}
+	resp.Header.Del(HeaderContentEncoding)
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody closes the decoder along with the underlying response body.
+type decodedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dlshle/gommon/zstd"
+)
+
+func TestClientCompressesRequestBodies(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get(HeaderContentEncoding) == EncodingGzip {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("invalid gzip body: %v", err)
+				return
+			}
+			body = reader
+		}
+		data, _ := io.ReadAll(body)
+		if strings.HasPrefix(r.URL.Path, "/flaky") && atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, r.Header.Get(HeaderContentEncoding)+"|"+strconv.FormatInt(r.ContentLength, 10)+"|"+strconv.Itoa(len(data)))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).CompressRequests(EncodingGzip, 1024).
+		AddInterceptor(RetryPolicyInterceptor(&RetryPolicy{BaseDelay: time.Millisecond, RetryNonIdempotent: true})).Build()
+	defer c.Stop()
+
+	large := strings.Repeat(`{"name":"gommon"},`, 1000)
+	for _, tc := range []struct {
+		path, body, encoding string
+	}{
+		{"/small", `{"name":"gommon"}`, ""},
+		{"/large", large, EncodingGzip},
+		{"/flaky", large, EncodingGzip},
+	} {
+		header := http.Header{}
+		req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL + tc.path).Header(header).StringBody(tc.body).Build()
+		resp, err := c.Request(req)
+		if err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected result %v, %v", tc.path, resp, err)
+		}
+		parts := strings.Split(string(resp.Body), "|")
+		if parts[0] != tc.encoding || parts[2] != strconv.Itoa(len(tc.body)) {
+			t.Errorf("%s: expected encoding %q and %d bytes, got %s", tc.path, tc.encoding, len(tc.body), resp.Body)
+		}
+		if tc.encoding != "" && parts[1] == strconv.Itoa(len(tc.body)) {
+			t.Errorf("%s: expected the compressed content length, got %s", tc.path, parts[1])
+		}
+		if header.Get(HeaderContentEncoding) != "" {
+			t.Errorf("%s: expected the caller's header to be left alone", tc.path)
+		}
+	}
+}
+
+func TestClientCompressesRequestsWithZstd(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderContentEncoding) != EncodingZstd {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(zstd.NewReader(r.Body))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).CompressRequests("ZSTD", 1024).Build()
+	defer c.Stop()
+
+	large := strings.Repeat(`{"name":"gommon"},`, 1000)
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).StringBody(large).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK || string(resp.Body) != large {
+		t.Fatalf("expected the zstd compressed body to be decoded, got %v, %v", resp, err)
+	}
+}
+
+func TestClientFailsRequestsWithUnknownEncodings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(HeaderContentEncoding)))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).CompressRequests("br", 1).Build()
+	defer c.Stop()
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).StringBody("gommon").Build()
+	if _, err := c.Request(req); !errors.Is(err, ErrUnknownContentEncoding) {
+		t.Errorf("expected ErrUnknownContentEncoding, got %v", err)
+	}
+
+	// codecs registered after CompressRequests are found
+	c = NewBuilder().TimeoutSec(5).CompressRequests("br", 1).ContentCodec(renamedCodec{gzipCodec{}, "br"}).Build()
+	defer c.Stop()
+	req, _ = NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).StringBody("gommon").Build()
+	if resp, err := c.Request(req); err != nil || string(resp.Body) != "br" {
+		t.Errorf("expected the br codec to be used, got %v, %v", resp, err)
+	}
+}
+
+// renamedCodec serves a built in codec under another encoding.
+type renamedCodec struct {
+	ContentCodec
+	encoding string
+}
+
+func (c renamedCodec) Encoding() string {
+	return c.encoding
+}
+
+func TestClientSignsCompressedRequestBodies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Header.Get(HeaderContentEncoding) != EncodingGzip || r.Header.Get(HeaderAmzContentHash) != hex.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).CompressRequests(EncodingGzip, 1024).
+		AddInterceptor(HMACSigningInterceptor(&HMACSigningOptions{
+			AccessKeyID:       "AKIDEXAMPLE",
+			SecretKey:         "secret",
+			Region:            "us-east-1",
+			Service:           "execute-api",
+			ContentHashHeader: true,
+		})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).StringBody(strings.Repeat(`{"name":"gommon"},`, 1000)).Build()
+	if resp, err := c.Request(req); err != nil || resp.Code != http.StatusOK {
+		t.Errorf("expected the signature to cover the compressed body, got %v, %v", resp, err)
+	}
+}
+
+func TestClientDecompressesResponses(t *testing.T) {
+	payload := strings.Repeat("gommon ", 200)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buffer bytes.Buffer
+		switch r.URL.Path {
+		case "/gzip":
+			writer := gzip.NewWriter(&buffer)
+			writer.Write([]byte(payload))
+			writer.Close()
+			w.Header().Set(HeaderContentEncoding, EncodingGzip)
+		case "/chained":
+			gzipWriter := gzip.NewWriter(&buffer)
+			zlibWriter := zlib.NewWriter(gzipWriter)
+			zlibWriter.Write([]byte(payload))
+			zlibWriter.Close()
+			gzipWriter.Close()
+			w.Header().Set(HeaderContentEncoding, "deflate, gzip")
+		case "/zstd":
+			writer := zstd.NewWriter(&buffer)
+			writer.Write([]byte(payload))
+			writer.Close()
+			w.Header().Set(HeaderContentEncoding, EncodingZstd)
+		case "/bomb":
+			writer := gzip.NewWriter(&buffer)
+			writer.Write(make([]byte, 1<<20))
+			writer.Close()
+			w.Header().Set(HeaderContentEncoding, EncodingGzip)
+		case "/unknown":
+			buffer.WriteString("opaque")
+			w.Header().Set(HeaderContentEncoding, "x-custom")
+		}
+		w.Header().Set("X-Accept-Encoding", r.Header.Get(HeaderAcceptEncoding))
+		w.Write(buffer.Bytes())
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).DecompressResponses(true).MaxResponseBodySize(64 * 1024).Build()
+	defer c.Stop()
+
+	for _, path := range []string{"/gzip", "/chained", "/zstd"} {
+		req, _ := NewRequestBuilder().URL(ts.URL + path).Build()
+		resp, err := c.Request(req)
+		if err != nil || string(resp.Body) != payload {
+			t.Fatalf("%s: expected the decompressed payload, got %v, %v", path, resp, err)
+		}
+		if resp.Header.Get(HeaderContentEncoding) != "" || resp.Header.Get("X-Accept-Encoding") != "deflate, gzip, zstd" {
+			t.Errorf("%s: unexpected headers %v", path, resp.Header)
+		}
+	}
+
+	req, _ := NewRequestBuilder().URL(ts.URL + "/unknown").Build()
+	if resp, err := c.Request(req); err != nil || string(resp.Body) != "opaque" || resp.Header.Get(HeaderContentEncoding) != "x-custom" {
+		t.Errorf("expected unknown encodings to be passed through, got %v, %v", resp, err)
+	}
+
+	req, _ = NewRequestBuilder().URL(ts.URL + "/bomb").Build()
+	if _, err := c.Request(req); err == nil || !strings.Contains(err.Error(), "exceeds maximum allowed size") {
+		t.Errorf("expected the decompressed size to be limited, got %v", err)
+	}
+}
@@ -3,16 +3,19 @@ package http
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/dlshle/gommon/logging"
 )
 
-// CurlInterceptor logs an equivalent curl command for the request before executing it.
-// WARNING: this logs the full request body and headers, which may include sensitive
-// data (credentials, PII, tokens). Use with care in production environments.
+// curlRedactor masks CurlInterceptor output with the default redaction rules.
+var curlRedactor = newRedactor(nil)
+
+// CurlInterceptor logs an equivalent curl command for the request before executing it. Credentials are masked
+// with DefaultRedactionRules and bodies are cut to DefaultDumpMaxBodySize, use DebugInterceptor to configure
+// them or to log responses as well.
 func CurlInterceptor(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
 	curl, err := requestToCurl(request)
 	if err != nil {
@@ -24,28 +27,36 @@ func CurlInterceptor(request *Request, next func(*Request) (*Response, error)) (
 }
 
 func requestToCurl(req *Request) (string, error) {
-	var curlCmd bytes.Buffer
-
-	curlCmd.WriteString("curl -X " + req.Method)
-
-	for key, values := range req.Header {
-		for _, value := range values {
-			curlCmd.WriteString(fmt.Sprintf(" -H '%s: %s'", key, value))
-		}
-	}
-
-	bodyBuf, err := snapshotRequestBody(req)
+	body, err := peekRequestBody(req, DefaultDumpMaxBodySize+1)
 	if err != nil {
 		return "", err
 	}
-	if bodyBuf.Len() > 0 {
-		bodyStr := strings.ReplaceAll(bodyBuf.String(), "'", "'\\''")
-		curlCmd.WriteString(fmt.Sprintf(" -d '%s'", bodyStr))
-	}
-
-	curlCmd.WriteString(" '" + req.URL.String() + "'")
+	var curl strings.Builder
+	writeCurlRequest(&curl, req, curlRedactor, dumpRequestBody(curlRedactor, req, body, DefaultDumpMaxBodySize))
+	return curl.String(), nil
+}
 
-	return curlCmd.String(), nil
+// peekRequestBody returns at most limit bytes of the request body without buffering the rest of it, so
+// streamed uploads are not held in memory. Bodies without GetBody are replaced by one that reads the peeked
+// bytes before the remainder.
+func peekRequestBody(req *Request, limit int64) ([]byte, error) {
+	if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(io.LimitReader(body, limit))
+	}
+	peeked, err := io.ReadAll(io.LimitReader(req.Body, limit))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), req.Body), req.Body}
+	return peeked, err
 }
 
 // snapshotRequestBody reads the request body for curl logging and leaves the
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dlshle/gommon/logging"
+)
+
+type DumpFormat string
+
+const (
+	// DumpFormatCurl dumps requests as curl commands.
+	DumpFormatCurl DumpFormat = "curl"
+	// DumpFormatRaw dumps requests as HTTP/1.1 wire messages.
+	DumpFormatRaw DumpFormat = "raw"
+
+	DefaultDumpMaxBodySize = 4096
+)
+
+type DebugOptions struct {
+	// Logger receives the dumps at info level, logging.GlobalLogger by default.
+	Logger logging.Logger
+	// Format of the request dump, DumpFormatCurl by default.
+	Format DumpFormat
+	// MaxBodySize truncates dumped bodies, DefaultDumpMaxBodySize by default. Negative values omit bodies.
+	MaxBodySize int
+	// Redaction masks sensitive values, DefaultRedactionRules by default.
+	Redaction *RedactionRules
+}
+
+// DebugInterceptor logs a dump of every request together with the response status, headers, truncated body
+// and the time it took. Sensitive headers and fields are masked according to the redaction rules, streamed
+// response bodies are left untouched and not dumped.
+func DebugInterceptor(opts *DebugOptions) Interceptor {
+	options := DebugOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	if options.Logger == nil {
+		options.Logger = logging.GlobalLogger
+	}
+	if options.Format == "" {
+		options.Format = DumpFormatCurl
+	}
+	if options.MaxBodySize == 0 {
+		options.MaxBodySize = DefaultDumpMaxBodySize
+	}
+	redactor := newRedactor(options.Redaction)
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		var dump strings.Builder
+		// one byte past MaxBodySize tells whether the body is truncated
+		body, err := peekRequestBody(request, int64(options.MaxBodySize)+1)
+		if err != nil {
+			options.Logger.Warnf(request.Context(), "failed to read request body for dump: %s", err)
+		}
+		requestBody := dumpRequestBody(redactor, request, body, options.MaxBodySize)
+		if options.Format == DumpFormatRaw {
+			writeRawRequest(&dump, request, redactor, requestBody)
+		} else {
+			writeCurlRequest(&dump, request, redactor, requestBody)
+		}
+
+		start := time.Now()
+		resp, err := next(request)
+		elapsed := time.Since(start)
+
+		if err != nil {
+			fmt.Fprintf(&dump, "\n\nfailed after %s: %s", elapsed, err)
+		} else {
+			fmt.Fprintf(&dump, "\n\nHTTP %d %s in %s\n", resp.Code, http.StatusText(resp.Code), elapsed)
+			if resp.Timings != nil {
+				dump.WriteString(resp.Timings.String() + "\n")
+			}
+			writeDumpHeader(&dump, resp.Header, redactor)
+			if resp.Stream != nil {
+				dump.WriteString("\n<streamed body>")
+			} else if responseBody := truncateDumpBody(redactor.body(resp.Header.Get("Content-Type"), resp.Body), options.MaxBodySize); responseBody != "" {
+				dump.WriteString("\n" + responseBody)
+			}
+		}
+		options.Logger.Info(request.Context(), dump.String())
+		return resp, err
+	}
+}
+
+func writeCurlRequest(dump *strings.Builder, request *Request, redactor *redactor, body string) {
+	dump.WriteString("curl -X " + request.Method)
+	for _, key := range sortedHeaderKeys(request.Header) {
+		for _, value := range request.Header[key] {
+			dump.WriteString(" -H " + shellQuote(key+": "+redactor.header(key, value)))
+		}
+	}
+	if body != "" {
+		dump.WriteString(" -d " + shellQuote(body))
+	}
+	dump.WriteString(" " + shellQuote(redactor.url(request.URL)))
+}
+
+func writeRawRequest(dump *strings.Builder, request *Request, redactor *redactor, body string) {
+	target := *request.URL
+	target.RawQuery = redactor.query(target.RawQuery)
+	fmt.Fprintf(dump, "%s %s HTTP/1.1\nHost: %s\n", request.Method, target.RequestURI(), request.URL.Host)
+	writeDumpHeader(dump, request.Header, redactor)
+	if body != "" {
+		dump.WriteString("\n" + body)
+	}
+}
+
+func writeDumpHeader(dump *strings.Builder, header http.Header, redactor *redactor) {
+	for _, key := range sortedHeaderKeys(header) {
+		for _, value := range header[key] {
+			dump.WriteString(key + ": " + redactor.header(key, value) + "\n")
+		}
+	}
+}
+
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func truncateDumpBody(body []byte, maxSize int) string {
+	if maxSize < 0 || len(body) == 0 {
+		return ""
+	}
+	if len(body) <= maxSize {
+		return string(body)
+	}
+	return fmt.Sprintf("%s... (%d bytes truncated)", body[:maxSize], len(body)-maxSize)
+}
+
+// dumpRequestBody redacts a request body peeked up to one byte past maxSize, the size of truncated request
+// bodies is unknown as they are not read in full.
+func dumpRequestBody(redactor *redactor, request *Request, body []byte, maxSize int) string {
+	if maxSize < 0 || len(body) == 0 {
+		return ""
+	}
+	contentType := request.Header.Get("Content-Type")
+	if len(body) <= maxSize {
+		return string(redactor.body(contentType, body))
+	}
+	return string(redactor.body(contentType, body[:maxSize])) + "... (truncated)"
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDebugInterceptorDumpsWithRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		io.WriteString(w, `{"access_token":"tok-123","user":{"name":"gommon"},"items":[`+strings.Repeat(`"x",`, 100)+`"x"]}`)
+	}))
+	defer ts.Close()
+
+	logger, writer := newCapturingLogger()
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(DebugInterceptor(&DebugOptions{Logger: logger, MaxBodySize: 80})).Build()
+	defer c.Stop()
+
+	header := http.Header{"Authorization": {"Bearer secret"}, "Content-Type": {"application/json"}, "X-Trace": {"it's"}}
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL + "/login?api_key=k1&page=2").Header(header).
+		StringBody(`{"user":"gommon","credentials":{"Password":"hunter2"}}`).Build()
+	resp, err := c.Request(req)
+	if err != nil || !strings.Contains(string(resp.Body), "tok-123") {
+		t.Fatalf("expected the response to be left untouched, got %v, %v", resp, err)
+	}
+
+	entities := writer.all()
+	if len(entities) != 1 {
+		t.Fatalf("expected one dump, got %d", len(entities))
+	}
+	dump := entities[0].Message
+	for _, expected := range []string{
+		"curl -X POST -H 'Authorization: [REDACTED]' -H 'Content-Type: application/json' -H 'X-Trace: it'\\''s'",
+		`-d '{"credentials":{"Password":"[REDACTED]"},"user":"gommon"}'`,
+		"'" + ts.URL + "/login?api_key=%5BREDACTED%5D&page=2'",
+		"HTTP 200 OK in ",
+		"Set-Cookie: [REDACTED]\n",
+		`{"access_token":"[REDACTED]","items":["x","x",`,
+		"bytes truncated)",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("expected %q in dump\n%s", expected, dump)
+		}
+	}
+	for _, secret := range []string{"Bearer secret", "hunter2", "k1", "tok-123", "session=abc"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("expected %q to be redacted from\n%s", secret, dump)
+		}
+	}
+}
+
+func TestDebugInterceptorRawFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	logger, writer := newCapturingLogger()
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(DebugInterceptor(&DebugOptions{
+			Logger:    logger,
+			Format:    DumpFormatRaw,
+			Redaction: &RedactionRules{Fields: []string{"pin"}, Replacement: "***"},
+		})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().Method(http.MethodPut).URL(ts.URL + "/cards/1").
+		FormBody(url.Values{"pin": {"1234"}, "name": {"gommon"}}).Build()
+	if _, err := c.Request(req); err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(ts.URL, "http://")
+	expected := "PUT /cards/1 HTTP/1.1\nHost: " + host + "\nContent-Type: application/x-www-form-urlencoded\n\nname=gommon&pin=%2A%2A%2A\n\nHTTP 404 Not Found in "
+	if dump := writer.all()[0].Message; !strings.HasPrefix(dump, expected) {
+		t.Errorf("unexpected dump\n%s\nexpected prefix\n%s", dump, expected)
+	}
+}
+
+// countingReader counts the bytes read from it, it may be read by a multipart encoding goroutine.
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	atomic.AddInt64(&r.read, int64(n))
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	return nil
+}
+
+func TestDebugInterceptorPeeksBoundedRequestBodies(t *testing.T) {
+	logger, writer := newCapturingLogger()
+	interceptor := DebugInterceptor(&DebugOptions{Logger: logger, MaxBodySize: 64})
+	respond := func(request *Request) (*Response, error) {
+		return &Response{Code: http.StatusOK}, nil
+	}
+
+	payload := `{"user":"gommon","password":"hunter2","blob":"` + strings.Repeat("x", 1<<20) + `"}`
+	stream := &countingReader{reader: strings.NewReader(payload)}
+	req, _ := http.NewRequest(http.MethodPost, "http://gommon.test/upload", stream)
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := interceptor(req, func(request *Request) (*Response, error) {
+		if body, _ := io.ReadAll(request.Body); string(body) != payload {
+			t.Errorf("expected the whole body to reach the next interceptor, got %d bytes", len(body))
+		}
+		return respond(request)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if stream.read != int64(len(payload)) {
+		t.Errorf("expected the body to be read once, got %d of %d bytes", stream.read, len(payload))
+	}
+	dump := writer.all()[0].Message
+	if !strings.Contains(dump, `"password":"[REDACTED]"`) || !strings.Contains(dump, "... (truncated)") || strings.Contains(dump, "hunter2") {
+		t.Errorf("expected a redacted and truncated body in\n%s", dump)
+	}
+
+	upload := &countingReader{reader: strings.NewReader(strings.Repeat("y", 8<<20))}
+	req, _ = NewRequestBuilder().Method(http.MethodPost).URL("http://gommon.test/upload").MultipartBody(
+		NewMultipartForm().File("data", "data.bin", func() (io.ReadCloser, error) { return upload, nil })).Build()
+	if _, err := interceptor(req, respond); err != nil {
+		t.Fatal(err)
+	}
+	if read := atomic.LoadInt64(&upload.read); read >= 1<<20 {
+		t.Errorf("expected the dump to read the start of the upload only, got %d bytes", read)
+	}
+
+	// the value of a sensitive field cut by the dump size is masked up to the cut
+	req, _ = NewRequestBuilder().Method(http.MethodPost).URL("http://gommon.test/token").
+		StringBody(`{"client":"gommon","token":"abcdefghijklmnopqrstuvwxyz0123456789abcdefghijklmnopqrstuvwxyz"}`).Build()
+	if _, err := interceptor(req, respond); err != nil {
+		t.Fatal(err)
+	}
+	if dump := writer.all()[2].Message; !strings.Contains(dump, `-d '{"client":"gommon","token":"[REDACTED]"... (truncated)'`) {
+		t.Errorf("expected the cut token to be masked in\n%s", dump)
+	}
+}
+
+func TestDebugInterceptorDefaultsNilOptions(t *testing.T) {
+	req, _ := NewRequestBuilder().URL("http://gommon.test/items").Build()
+	resp, err := DebugInterceptor(nil)(req, func(request *Request) (*Response, error) {
+		return &Response{Code: http.StatusNoContent}, nil
+	})
+	if err != nil || resp.Code != http.StatusNoContent {
+		t.Errorf("unexpected result %v, %v", resp, err)
+	}
+}
+
+func TestCurlInterceptorRedacts(t *testing.T) {
+	header := http.Header{"Authorization": {"Bearer secret"}, "Content-Type": {"application/json"}}
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL("http://gommon.test/login?access_token=t1").Header(header).
+		StringBody(`{"user":"gommon","password":"hunter2"}`).Build()
+	curl, err := requestToCurl(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `curl -X POST -H 'Authorization: [REDACTED]' -H 'Content-Type: application/json' ` +
+		`-d '{"password":"[REDACTED]","user":"gommon"}' 'http://gommon.test/login?access_token=%5BREDACTED%5D'`
+	if curl != expected {
+		t.Errorf("unexpected curl command\n%s\nexpected\n%s", curl, expected)
+	}
+}
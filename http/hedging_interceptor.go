@@ -0,0 +1,181 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderXHedgeAttempt reports which attempt answered a hedged request, 0 being the original one
+	HeaderXHedgeAttempt = "X-Hedge-Attempt"
+
+	DefaultHedgeInitialDelay = 100 * time.Millisecond
+	DefaultHedgeMinDelay     = 5 * time.Millisecond
+	DefaultHedgeMaxHedges    = 1
+	DefaultHedgeWindowSize   = 100
+	DefaultHedgeMinSamples   = 20
+	DefaultHedgePercentile   = 0.95
+)
+
+type HedgingOptions struct {
+	// Delay is a fixed hedge delay, when 0 the delay follows Percentile of the observed latencies.
+	Delay time.Duration
+	// InitialDelay is used until MinSamples latencies are observed.
+	InitialDelay time.Duration
+	MinDelay     time.Duration
+	Percentile   float64
+	WindowSize   int
+	MinSamples   int
+	// MaxHedges is the number of extra attempts per request.
+	MaxHedges int
+	// ShouldHedge selects the requests to hedge, GET and HEAD requests that are not streamed by default.
+	ShouldHedge func(request *Request) bool
+}
+
+type hedgeAttemptKey struct{}
+
+// HedgingInterceptor sends another attempt of a request that has not been answered after the hedge delay, the
+// first successful response wins and the other attempts are cancelled. Extra attempts are queued on the client
+// like any other request and only run the interceptors after this one. The winning attempt is reported in the
+// X-Hedge-Attempt response header. Only idempotent requests may be hedged.
+func HedgingInterceptor(opts *HedgingOptions) Interceptor {
+	h := newHedger(opts)
+	return h.intercept
+}
+
+type hedger struct {
+	opts      HedgingOptions
+	mutex     *sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+type hedgeResult struct {
+	attempt  int
+	resp     *Response
+	err      error
+	duration time.Duration
+}
+
+func newHedger(opts *HedgingOptions) *hedger {
+	options := HedgingOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	if options.InitialDelay <= 0 {
+		options.InitialDelay = DefaultHedgeInitialDelay
+	}
+	if options.MinDelay <= 0 {
+		options.MinDelay = DefaultHedgeMinDelay
+	}
+	if options.Percentile <= 0 || options.Percentile > 1 {
+		options.Percentile = DefaultHedgePercentile
+	}
+	if options.WindowSize <= 0 {
+		options.WindowSize = DefaultHedgeWindowSize
+	}
+	if options.MinSamples <= 0 {
+		options.MinSamples = DefaultHedgeMinSamples
+	}
+	if options.MaxHedges <= 0 {
+		options.MaxHedges = DefaultHedgeMaxHedges
+	}
+	if options.ShouldHedge == nil {
+		options.ShouldHedge = func(request *Request) bool {
+			return (request.Method == http.MethodGet || request.Method == http.MethodHead) &&
+				!isStreamResponseRequested(request.Context())
+		}
+	}
+	return &hedger{opts: options, mutex: new(sync.Mutex)}
+}
+
+func (h *hedger) record(latency time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.latencies) < h.opts.WindowSize {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % h.opts.WindowSize
+}
+
+func (h *hedger) delay() time.Duration {
+	if h.opts.Delay > 0 {
+		return h.opts.Delay
+	}
+	h.mutex.Lock()
+	if len(h.latencies) < h.opts.MinSamples {
+		h.mutex.Unlock()
+		return h.opts.InitialDelay
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	h.mutex.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	delay := sorted[int(float64(len(sorted)-1)*h.opts.Percentile)]
+	if delay < h.opts.MinDelay {
+		return h.opts.MinDelay
+	}
+	return delay
+}
+
+func (h *hedger) intercept(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+	if _, isHedge := request.Context().Value(hedgeAttemptKey{}).(int); isHedge || !h.opts.ShouldHedge(request) {
+		return next(request)
+	}
+	submit, ok := requestSubmitterFromContext(request.Context())
+	if !ok {
+		// not executed by a client with a worker queue
+		return next(request)
+	}
+	ctx, cancel := context.WithCancel(request.Context())
+	// cancels the losing attempts
+	defer cancel()
+
+	results := make(chan hedgeResult, h.opts.MaxHedges+1)
+	launch := func(attempt int, run func() (*Response, error)) {
+		go func() {
+			start := time.Now()
+			resp, err := run()
+			results <- hedgeResult{attempt, resp, err, time.Since(start)}
+		}()
+	}
+	launch(0, func() (*Response, error) {
+		return next(request.WithContext(ctx))
+	})
+	launched, pending := 1, 1
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+	hedgeTimer := timer.C
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-hedgeTimer:
+			hedge := request.Clone(context.WithValue(ctx, hedgeAttemptKey{}, launched))
+			awaitable := submit(hedge, next)
+			launch(launched, awaitable.Get)
+			launched++
+			pending++
+			if launched > h.opts.MaxHedges {
+				hedgeTimer = nil
+			} else {
+				timer.Reset(h.delay())
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				lastErr = result.err
+				continue
+			}
+			h.record(result.duration)
+			result.resp.Header = withHeader(result.resp.Header, HeaderXHedgeAttempt, strconv.Itoa(result.attempt))
+			return result.resp, nil
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgingInterceptorFirstResponseWins(t *testing.T) {
+	var hits int32
+	cancelled := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				cancelled <- struct{}{}
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(2).TimeoutSec(5).
+		AddInterceptor(HedgingInterceptor(&HedgingOptions{Delay: 20 * time.Millisecond})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Build()
+	start := time.Now()
+	resp, err := c.Request(req)
+	if err != nil || string(resp.Body) != "ok" {
+		t.Fatalf("unexpected response %v, %v", resp, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the hedge to answer first, took %s", elapsed)
+	}
+	if attempt := resp.Header.Get(HeaderXHedgeAttempt); attempt != "1" {
+		t.Errorf("expected the hedged attempt to win, got %q", attempt)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("expected the losing attempt to be cancelled")
+	}
+}
+
+func TestHedgingInterceptorSkipsNonIdempotentRequests(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(2).TimeoutSec(5).
+		AddInterceptor(HedgingInterceptor(&HedgingOptions{Delay: 5 * time.Millisecond})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).StringBody("x").Build()
+	resp, err := c.Request(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 || resp.Header.Get(HeaderXHedgeAttempt) != "" {
+		t.Errorf("expected POST not to be hedged, got %d hits", n)
+	}
+}
+
+func TestHedgingInterceptorWaitsForWorker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	// the only worker runs the original attempt, so the hedge stays queued until it is cancelled
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(HedgingInterceptor(&HedgingOptions{Delay: 5 * time.Millisecond})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Header.Get(HeaderXHedgeAttempt) != "0" {
+		t.Fatalf("expected the original attempt to win, got %v, %v", resp, err)
+	}
+	req, _ = NewRequestBuilder().URL(ts.URL).Build()
+	if _, err = c.Request(req); err != nil {
+		t.Errorf("expected the client to stay usable, got %v", err)
+	}
+}
+
+func TestHedgerDelayFollowsPercentile(t *testing.T) {
+	h := newHedger(&HedgingOptions{MinSamples: 10, WindowSize: 100})
+	if delay := h.delay(); delay != DefaultHedgeInitialDelay {
+		t.Errorf("expected the initial delay without samples, got %s", delay)
+	}
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+	if delay := h.delay(); delay != 95*time.Millisecond {
+		t.Errorf("expected the p95 latency, got %s", delay)
+	}
+	for i := 0; i < 100; i++ {
+		h.record(time.Microsecond)
+	}
+	if delay := h.delay(); delay != DefaultHedgeMinDelay {
+		t.Errorf("expected the delay to be floored, got %s", delay)
+	}
+	if delay := newHedger(nil).delay(); delay != DefaultHedgeInitialDelay {
+		t.Errorf("expected nil options to be defaulted, got %s", delay)
+	}
+}
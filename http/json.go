@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const contentTypeJSON = "application/json"
+
+// HTTPError is returned by the JSON helpers for non-2xx responses.
+type HTTPError struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+	URI    string
+}
+
+func (e *HTTPError) Error() string {
+	const maxBodyInError = 512
+	body := e.Body
+	if len(body) > maxBodyInError {
+		body = body[:maxBodyInError]
+	}
+	return fmt.Sprintf("request to %s failed with status %d: %s", e.URI, e.Code, string(body))
+}
+
+type JSONRequestOptions struct {
+	Header  http.Header
+	Timeout time.Duration
+	// MaxResponseBodySize rejects larger responses without reading past the limit, 0 leaves it to the client's
+	// own limit.
+	MaxResponseBodySize int64
+}
+
+type JSONRequestOpt func(*JSONRequestOptions) *JSONRequestOptions
+
+func WithJSONHeader(key, value string) JSONRequestOpt {
+	return func(o *JSONRequestOptions) *JSONRequestOptions {
+		o.Header.Set(key, value)
+		return o
+	}
+}
+
+func WithJSONTimeout(timeout time.Duration) JSONRequestOpt {
+	return func(o *JSONRequestOptions) *JSONRequestOptions {
+		o.Timeout = timeout
+		return o
+	}
+}
+
+func WithJSONMaxResponseBodySize(n int64) JSONRequestOpt {
+	return func(o *JSONRequestOptions) *JSONRequestOptions {
+		o.MaxResponseBodySize = n
+		return o
+	}
+}
+
+func GetJSON[Resp any](ctx context.Context, c Client, url string, opts ...JSONRequestOpt) (Resp, error) {
+	return doJSON[Resp](ctx, c, http.MethodGet, url, nil, opts...)
+}
+
+func DeleteJSON[Resp any](ctx context.Context, c Client, url string, opts ...JSONRequestOpt) (Resp, error) {
+	return doJSON[Resp](ctx, c, http.MethodDelete, url, nil, opts...)
+}
+
+func PostJSON[Req, Resp any](ctx context.Context, c Client, url string, body Req, opts ...JSONRequestOpt) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, c, http.MethodPost, url, body, opts...)
+}
+
+func PutJSON[Req, Resp any](ctx context.Context, c Client, url string, body Req, opts ...JSONRequestOpt) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, c, http.MethodPut, url, body, opts...)
+}
+
+func PatchJSON[Req, Resp any](ctx context.Context, c Client, url string, body Req, opts ...JSONRequestOpt) (Resp, error) {
+	return DoJSON[Req, Resp](ctx, c, http.MethodPatch, url, body, opts...)
+}
+
+// DoJSON sends body encoded as JSON and decodes a 2xx response into Resp; other status codes yield *HTTPError.
+func DoJSON[Req, Resp any](ctx context.Context, c Client, method, url string, body Req, opts ...JSONRequestOpt) (resp Resp, err error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return resp, fmt.Errorf("encode request body: %w", err)
+	}
+	return doJSON[Resp](ctx, c, method, url, encoded, opts...)
+}
+
+func doJSON[Resp any](ctx context.Context, c Client, method, url string, body []byte, opts ...JSONRequestOpt) (holder Resp, err error) {
+	options := &JSONRequestOptions{Header: http.Header{}}
+	for _, opt := range opts {
+		options = opt(options)
+	}
+	header := options.Header.Clone()
+	if header.Get("Accept") == "" {
+		header.Set("Accept", contentTypeJSON)
+	}
+	// responses are streamed under a per-call limit so oversized bodies are never read into memory in full
+	limited := options.MaxResponseBodySize > 0
+	builder := NewRequestBuilder().Context(ctx).Method(method).URL(url).Timeout(options.Timeout).Stream(limited)
+	if body != nil {
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", contentTypeJSON)
+		}
+		builder = builder.BytesBody(body)
+	}
+	request, err := builder.Header(header).Build()
+	if err != nil {
+		return holder, err
+	}
+	resp, err := c.Request(request)
+	if err != nil {
+		return holder, err
+	}
+	if limited {
+		if err := readLimitedStream(resp, options.MaxResponseBodySize); err != nil {
+			return holder, err
+		}
+	}
+	if resp.Code < 200 || resp.Code > 299 {
+		return holder, &HTTPError{resp.Code, resp.Header, resp.Body, resp.URI}
+	}
+	if len(resp.Body) == 0 {
+		return holder, nil
+	}
+	return ParseJSONResponseBody[Resp](resp)
+}
+
+// readLimitedStream buffers the stream of resp into its Body, reading at most maxSize+1 bytes.
+func readLimitedStream(resp *Response, maxSize int64) error {
+	if stream := resp.Stream; stream != nil {
+		defer stream.Close()
+		body, err := io.ReadAll(io.LimitReader(stream, maxSize+1))
+		if err != nil {
+			return err
+		}
+		resp.Body, resp.Stream = body, nil
+	}
+	if int64(len(resp.Body)) > maxSize {
+		return fmt.Errorf("response body exceeds maximum allowed size of %d bytes", maxSize)
+	}
+	return nil
+}
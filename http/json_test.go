@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type jsonTestPayload struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestJSONHelpers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "missing accept header", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if r.Header.Get("X-Token") != "abc" {
+				http.Error(w, "missing custom header", http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(jsonTestPayload{Name: "get", Count: 1})
+		case http.MethodPost:
+			if r.Header.Get("Content-Type") != "application/json" {
+				http.Error(w, "missing content type", http.StatusBadRequest)
+				return
+			}
+			var payload jsonTestPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			payload.Count++
+			_ = json.NewEncoder(w).Encode(payload)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).Build()
+	defer c.Stop()
+
+	got, err := GetJSON[jsonTestPayload](context.Background(), c, ts.URL, WithJSONHeader("X-Token", "abc"))
+	if err != nil || got.Name != "get" || got.Count != 1 {
+		t.Fatalf("unexpected GetJSON result %+v, %v", got, err)
+	}
+	posted, err := PostJSON[jsonTestPayload, jsonTestPayload](context.Background(), c, ts.URL, jsonTestPayload{Name: "post", Count: 1})
+	if err != nil || posted.Name != "post" || posted.Count != 2 {
+		t.Fatalf("unexpected PostJSON result %+v, %v", posted, err)
+	}
+	if _, err = DeleteJSON[struct{}](context.Background(), c, ts.URL); err != nil {
+		t.Fatalf("expected empty 204 response to decode, got %v", err)
+	}
+}
+
+func TestJSONHelpersMapNon2xxToHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).Build()
+	defer c.Stop()
+
+	_, err := GetJSON[jsonTestPayload](context.Background(), c, ts.URL)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected *HTTPError, got %v", err)
+	}
+	if httpErr.Code != http.StatusNotFound || !strings.Contains(string(httpErr.Body), "not found") {
+		t.Errorf("unexpected HTTPError %+v", httpErr)
+	}
+}
+
+func TestJSONHelpersMaxResponseBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jsonTestPayload{Name: strings.Repeat("x", 100)})
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).Build()
+	defer c.Stop()
+
+	if _, err := GetJSON[jsonTestPayload](context.Background(), c, ts.URL, WithJSONMaxResponseBodySize(16)); err == nil {
+		t.Fatal("expected oversized response to be rejected")
+	}
+	if resp, err := GetJSON[jsonTestPayload](context.Background(), c, ts.URL, WithJSONMaxResponseBodySize(1024)); err != nil || len(resp.Name) != 100 {
+		t.Errorf("expected a response within the limit to be decoded, got %v, %v", resp, err)
+	}
+}
+
+func TestJSONHelpersMaxResponseBodySizeStopsReading(t *testing.T) {
+	const total = 64 << 20
+	var written int64
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		chunk := []byte(strings.Repeat("x", 1024))
+		for written < total {
+			n, err := w.Write(chunk)
+			written += int64(n)
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).Build()
+	defer c.Stop()
+
+	if _, err := GetJSON[jsonTestPayload](context.Background(), c, ts.URL, WithJSONMaxResponseBodySize(1024)); err == nil {
+		t.Fatal("expected oversized response to be rejected")
+	}
+	<-done
+	if written >= total {
+		t.Errorf("expected the client to stop reading the oversized body, the server wrote all %d bytes", written)
+	}
+}
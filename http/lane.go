@@ -0,0 +1,76 @@
+package http
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// DefaultLaneKey is the key of the lane serving requests that match no configured lane.
+const DefaultLaneKey = "default"
+
+// LaneOptions configures a lane, a set of workers with its own queue so a slow upstream only holds back the
+// requests of its own lane. Values below 1 are raised to 1.
+type LaneOptions struct {
+	MaxConcurrentRequests int
+	MaxQueueSize          int
+}
+
+type LaneStats struct {
+	Key                   string
+	QueueDepth            int
+	MaxQueueSize          int
+	InFlight              int
+	MaxConcurrentRequests int
+}
+
+type ClientStats struct {
+	// Lanes starts with the default lane, followed by the configured lanes.
+	Lanes []LaneStats
+}
+
+// Lane returns the stats of the lane with key.
+func (s ClientStats) Lane(key string) (LaneStats, bool) {
+	for _, lane := range s.Lanes {
+		if lane.Key == key {
+			return lane, true
+		}
+	}
+	return LaneStats{}, false
+}
+
+// StatsProvider is implemented by clients that report their lane stats, clients built by HTTPClientBuilder do:
+//
+//	stats := client.(StatsProvider).Stats()
+type StatsProvider interface {
+	Stats() ClientStats
+}
+
+// LaneKeyByHost puts requests of each host into its own lane, it is the default lane key function.
+func LaneKeyByHost(request *http.Request) string {
+	return request.URL.Host
+}
+
+type lane struct {
+	key        string
+	queue      *requestQueue
+	workerSize int
+	inFlight   int32
+}
+
+func newLane(key string, workerSize, queueSize int) *lane {
+	return &lane{
+		key:        key,
+		queue:      newRequestQueue(queueSize),
+		workerSize: workerSize,
+	}
+}
+
+func (l *lane) stats() LaneStats {
+	return LaneStats{
+		Key:                   l.key,
+		QueueDepth:            l.queue.size(),
+		MaxQueueSize:          l.queue.maxSize,
+		InFlight:              int(atomic.LoadInt32(&l.inFlight)),
+		MaxConcurrentRequests: l.workerSize,
+	}
+}
@@ -0,0 +1,259 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// DefaultLatencyBuckets are the histogram buckets in seconds used unless a histogram has its own buckets.
+	DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets are histogram buckets in bytes meant for body sizes.
+	DefaultSizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216}
+)
+
+type MetricLabels map[string]string
+
+// MetricsRegistry records metrics, a series is identified by its name and labels.
+type MetricsRegistry interface {
+	AddCounter(name string, labels MetricLabels, delta float64)
+	AddGauge(name string, labels MetricLabels, delta float64)
+	ObserveHistogram(name string, labels MetricLabels, value float64)
+}
+
+const (
+	metricKindCounter   = "counter"
+	metricKindGauge     = "gauge"
+	metricKindHistogram = "histogram"
+)
+
+type metricSeries struct {
+	labels MetricLabels
+	value  float64
+	// buckets holds the non-cumulative counts of a histogram, the last one counts values above all bounds
+	buckets []uint64
+	count   uint64
+}
+
+type metricFamily struct {
+	kind    string
+	help    string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// InMemoryMetricsRegistry keeps metrics in memory and exposes them in the Prometheus text format.
+type InMemoryMetricsRegistry struct {
+	mutex    *sync.Mutex
+	families map[string]*metricFamily
+	help     map[string]string
+	buckets  map[string][]float64
+}
+
+func NewMetricsRegistry() *InMemoryMetricsRegistry {
+	return &InMemoryMetricsRegistry{
+		mutex:    new(sync.Mutex),
+		families: make(map[string]*metricFamily),
+		help:     make(map[string]string),
+		buckets:  make(map[string][]float64),
+	}
+}
+
+// SetHelp sets the HELP line of a metric.
+func (r *InMemoryMetricsRegistry) SetHelp(name, help string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.help[name] = help
+	if family, ok := r.families[name]; ok {
+		family.help = help
+	}
+}
+
+// SetBuckets sets the upper bounds of a histogram, it only applies to histograms without observations yet.
+func (r *InMemoryMetricsRegistry) SetBuckets(name string, buckets []float64) {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.buckets[name] = buckets
+}
+
+func (r *InMemoryMetricsRegistry) AddCounter(name string, labels MetricLabels, delta float64) {
+	if delta < 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if series := r.seriesOf(name, metricKindCounter, labels); series != nil {
+		series.value += delta
+	}
+}
+
+func (r *InMemoryMetricsRegistry) AddGauge(name string, labels MetricLabels, delta float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if series := r.seriesOf(name, metricKindGauge, labels); series != nil {
+		series.value += delta
+	}
+}
+
+func (r *InMemoryMetricsRegistry) ObserveHistogram(name string, labels MetricLabels, value float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	series := r.seriesOf(name, metricKindHistogram, labels)
+	if series == nil {
+		return
+	}
+	bounds := r.families[name].buckets
+	series.buckets[sort.SearchFloat64s(bounds, value)]++
+	series.value += value
+	series.count++
+}
+
+// seriesOf returns the series of name and labels, or nil if name is already registered with another kind.
+func (r *InMemoryMetricsRegistry) seriesOf(name, kind string, labels MetricLabels) *metricSeries {
+	family, ok := r.families[name]
+	if !ok {
+		family = &metricFamily{kind: kind, help: r.help[name], series: make(map[string]*metricSeries)}
+		if kind == metricKindHistogram {
+			family.buckets = DefaultLatencyBuckets
+			if buckets, ok := r.buckets[name]; ok {
+				family.buckets = buckets
+			}
+		}
+		r.families[name] = family
+	}
+	if family.kind != kind {
+		return nil
+	}
+	key := formatMetricLabels(labels, "", "")
+	series, ok := family.series[key]
+	if !ok {
+		series = &metricSeries{labels: copyMetricLabels(labels)}
+		if kind == metricKindHistogram {
+			series.buckets = make([]uint64, len(family.buckets)+1)
+		}
+		family.series[key] = series
+	}
+	return series
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format, sorted by name and labels.
+func (r *InMemoryMetricsRegistry) WritePrometheus(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	writer := bufio.NewWriter(w)
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := r.families[name]
+		if family.help != "" {
+			writer.WriteString("# HELP " + name + " " + escapeMetricHelp(family.help) + "\n")
+		}
+		writer.WriteString("# TYPE " + name + " " + family.kind + "\n")
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := family.series[key]
+			if family.kind != metricKindHistogram {
+				writer.WriteString(name + key + " " + formatMetricValue(series.value) + "\n")
+				continue
+			}
+			var cumulative uint64
+			for i, count := range series.buckets {
+				cumulative += count
+				bound := math.Inf(1)
+				if i < len(family.buckets) {
+					bound = family.buckets[i]
+				}
+				writer.WriteString(name + "_bucket" + formatMetricLabels(series.labels, "le", formatMetricValue(bound)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+			}
+			writer.WriteString(name + "_sum" + key + " " + formatMetricValue(series.value) + "\n")
+			writer.WriteString(name + "_count" + key + " " + strconv.FormatUint(series.count, 10) + "\n")
+		}
+	}
+	return writer.Flush()
+}
+
+// PrometheusHandler serves the metrics of registry for Prometheus to scrape.
+func PrometheusHandler(registry *InMemoryMetricsRegistry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, request *Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		registry.WritePrometheus(w)
+	})
+}
+
+func copyMetricLabels(labels MetricLabels) MetricLabels {
+	copied := make(MetricLabels, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// formatMetricLabels formats labels sorted by name, extraKey is appended last when set.
+func formatMetricLabels(labels MetricLabels, extraKey, extraValue string) string {
+	if len(labels) == 0 && extraKey == "" {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var builder strings.Builder
+	builder.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(key + `="` + escapeMetricLabelValue(labels[key]) + `"`)
+	}
+	if extraKey != "" {
+		if len(keys) > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(extraKey + `="` + escapeMetricLabelValue(extraValue) + `"`)
+	}
+	builder.WriteByte('}')
+	return builder.String()
+}
+
+var (
+	metricLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	metricHelpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeMetricLabelValue(value string) string {
+	return metricLabelValueReplacer.Replace(value)
+}
+
+func escapeMetricHelp(help string) string {
+	return metricHelpReplacer.Replace(help)
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MetricRequestsTotal     = "http_client_requests_total"
+	MetricRequestDuration   = "http_client_request_duration_seconds"
+	MetricQueueWait         = "http_client_queue_wait_seconds"
+	MetricInFlightRequests  = "http_client_in_flight_requests"
+	MetricRetriesTotal      = "http_client_retries_total"
+	MetricResponseBodyBytes = "http_client_response_body_bytes"
+
+	MetricLabelHost   = "host"
+	MetricLabelRoute  = "route"
+	MetricLabelMethod = "method"
+	MetricLabelStatus = "status"
+
+	// MetricStatusError is the status label of requests that failed without a response.
+	MetricStatusError = "error"
+	// MetricRouteUnknown is the route label of requests when no Route function is set.
+	MetricRouteUnknown = "unknown"
+)
+
+type MetricsOptions struct {
+	// Registry records the metrics, a new InMemoryMetricsRegistry by default.
+	Registry MetricsRegistry
+	// Route names the route label of a request, typically the path template such as /users/{id}. Its values
+	// must stay bounded, every value is a new series. MetricRouteUnknown for all requests by default.
+	Route func(request *Request) string
+}
+
+// MetricsInterceptor records request counts and latencies per host, route, method and status, along with queue
+// wait times, in-flight requests, retries and response body sizes. Register it before RetryInterceptor so the
+// latency covers all attempts and retries are counted.
+func MetricsInterceptor(opts *MetricsOptions) Interceptor {
+	options := MetricsOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	if options.Registry == nil {
+		options.Registry = NewMetricsRegistry()
+	}
+	if options.Route == nil {
+		options.Route = func(request *Request) string {
+			return MetricRouteUnknown
+		}
+	}
+	if registry, ok := options.Registry.(*InMemoryMetricsRegistry); ok {
+		registry.SetHelp(MetricRequestsTotal, "Total number of HTTP client requests.")
+		registry.SetHelp(MetricRequestDuration, "Latency of HTTP client requests in seconds, including retries.")
+		registry.SetHelp(MetricQueueWait, "Time requests waited in the client queue in seconds.")
+		registry.SetHelp(MetricInFlightRequests, "Number of HTTP client requests in flight.")
+		registry.SetHelp(MetricRetriesTotal, "Total number of HTTP client retries.")
+		registry.SetHelp(MetricResponseBodyBytes, "Size of HTTP client response bodies in bytes.")
+		registry.SetBuckets(MetricResponseBodyBytes, DefaultSizeBuckets)
+	}
+	registry := options.Registry
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		host := MetricLabels{MetricLabelHost: request.URL.Host}
+		if wait, ok := QueueWait(request.Context()); ok {
+			registry.ObserveHistogram(MetricQueueWait, host, wait.Seconds())
+		}
+		registry.AddGauge(MetricInFlightRequests, host, 1)
+		defer registry.AddGauge(MetricInFlightRequests, host, -1)
+
+		ctx, attempts := withAttemptCounter(request.Context())
+		start := time.Now()
+		resp, err := next(request.WithContext(ctx))
+		duration := time.Since(start)
+
+		labels := MetricLabels{
+			MetricLabelHost:   request.URL.Host,
+			MetricLabelRoute:  options.Route(request),
+			MetricLabelMethod: request.Method,
+		}
+		if n := atomic.LoadInt32(attempts); n > 1 {
+			registry.AddCounter(MetricRetriesTotal, labels, float64(n-1))
+		}
+		if resp != nil && resp.Stream == nil {
+			registry.ObserveHistogram(MetricResponseBodyBytes, labels, float64(len(resp.Body)))
+		}
+		labels[MetricLabelStatus] = MetricStatusError
+		if resp != nil {
+			labels[MetricLabelStatus] = strconv.Itoa(resp.Code)
+		}
+		registry.AddCounter(MetricRequestsTotal, labels, 1)
+		registry.ObserveHistogram(MetricRequestDuration, labels, duration.Seconds())
+		return resp, err
+	}
+}
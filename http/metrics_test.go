@@ -0,0 +1,110 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetricsInterceptorRecordsRequests(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "hello")
+	}))
+	defer ts.Close()
+	host := strings.TrimPrefix(ts.URL, "http://")
+
+	registry := NewMetricsRegistry()
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).WithInterceptors(
+		MetricsInterceptor(&MetricsOptions{Registry: registry, Route: func(request *Request) string {
+			return request.URL.Path
+		}}),
+		RetryPolicyInterceptor(&RetryPolicy{BaseDelay: time.Millisecond}),
+	).Build()
+	defer c.Stop()
+
+	for _, path := range []string{"/flaky", "/ok", "/ok"} {
+		req, _ := NewRequestBuilder().URL(ts.URL + path).Build()
+		if resp, err := c.Request(req); err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected result %v, %v", path, resp, err)
+		}
+	}
+	req, _ := NewRequestBuilder().URL("http://127.0.0.1:1/down").Build()
+	if _, err := c.Request(req); err == nil {
+		t.Fatalf("expected the request to an unreachable host to fail")
+	}
+
+	resp := serveTestRequest(PrometheusHandler(registry), http.MethodGet, "/metrics")
+	if contentType := resp.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	body := resp.Body.String()
+	for _, line := range []string{
+		"# TYPE http_client_requests_total counter",
+		`http_client_requests_total{host="` + host + `",method="GET",route="/ok",status="200"} 2`,
+		`http_client_requests_total{host="` + host + `",method="GET",route="/flaky",status="200"} 1`,
+		`http_client_requests_total{host="127.0.0.1:1",method="GET",route="/down",status="error"} 1`,
+		`http_client_retries_total{host="` + host + `",method="GET",route="/flaky"} 1`,
+		`http_client_request_duration_seconds_count{host="` + host + `",method="GET",route="/ok",status="200"} 2`,
+		`http_client_request_duration_seconds_bucket{host="` + host + `",method="GET",route="/ok",status="200",le="+Inf"} 2`,
+		`http_client_response_body_bytes_bucket{host="` + host + `",method="GET",route="/ok",le="256"} 2`,
+		`http_client_response_body_bytes_sum{host="` + host + `",method="GET",route="/ok"} 10`,
+		`http_client_queue_wait_seconds_count{host="` + host + `"} 3`,
+		`http_client_in_flight_requests{host="` + host + `"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %q in\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "http_client_retries_total{host=\""+host+"\",method=\"GET\",route=\"/ok\"}") {
+		t.Errorf("expected no retries for /ok")
+	}
+
+	// paths are not used as routes unless asked to
+	registry = NewMetricsRegistry()
+	_, _ = MetricsInterceptor(&MetricsOptions{Registry: registry})(req, func(r *Request) (*Response, error) {
+		return &Response{Code: http.StatusNoContent}, nil
+	})
+	body = serveTestRequest(PrometheusHandler(registry), http.MethodGet, "/metrics").Body.String()
+	if line := `http_client_requests_total{host="127.0.0.1:1",method="GET",route="unknown",status="204"} 1`; !strings.Contains(body, line) {
+		t.Errorf("expected %q in\n%s", line, body)
+	}
+}
+
+func TestMetricsRegistryExposition(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.SetHelp("jobs_total", "Jobs run.\nPer queue.")
+	registry.SetBuckets("job_seconds", []float64{1, 0.1})
+	registry.AddCounter("jobs_total", MetricLabels{"queue": `a"b\c`}, 2)
+	registry.AddCounter("jobs_total", MetricLabels{"queue": `a"b\c`}, -1)
+	registry.AddGauge("jobs_total", nil, 1)
+	registry.ObserveHistogram("job_seconds", nil, 0.1)
+	registry.ObserveHistogram("job_seconds", nil, 0.5)
+	registry.ObserveHistogram("job_seconds", nil, 3)
+
+	var builder strings.Builder
+	if err := registry.WritePrometheus(&builder); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# TYPE job_seconds histogram
+job_seconds_bucket{le="0.1"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.6
+job_seconds_count 3
+# HELP jobs_total Jobs run.\nPer queue.
+# TYPE jobs_total counter
+jobs_total{queue="a\"b\\c"} 2
+`
+	if builder.String() != expected {
+		t.Errorf("unexpected exposition\n%s\nexpected\n%s", builder.String(), expected)
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"sync"
 )
@@ -10,9 +11,81 @@ import (
 type MockHTTPClient struct {
 	responses    map[string][]*Response // Map of request keys to response sequences
 	requestCount map[string]int         // Tracks how many times each request has been made
+	cassette     *cassettePlayer        // Answers all requests when the mock runs in cassette mode
+	stubs        []*MockStub            // Matcher based stubs, consulted in registration order
+	requests     []*http.Request        // Every request received by the mock
 	mutex        sync.Mutex
 }
 
+// MockStub answers the requests matching all of its matchers and records them for verification
+type MockStub struct {
+	matchers  []RequestMatcher
+	responder MockResponder
+	remaining int // number of requests the stub still answers, negative for unlimited
+	requests  []*http.Request
+	mutex     sync.Mutex
+}
+
+// Respond sets a fixed response for the stub
+func (s *MockStub) Respond(response *Response) *MockStub {
+	return s.RespondWith(RespondWith(response))
+}
+
+// RespondWith sets a dynamic responder for the stub, it can inject errors and delays
+func (s *MockStub) RespondWith(responder MockResponder) *MockStub {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.responder = responder
+	return s
+}
+
+// Times limits the stub to the next n matching requests, later requests fall through to other stubs
+func (s *MockStub) Times(n int) *MockStub {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.remaining = n
+	return s
+}
+
+// Requests returns the requests answered by the stub
+func (s *MockStub) Requests() []*http.Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// AssertCalled returns an error unless the stub answered exactly times requests
+func (s *MockStub) AssertCalled(times int) error {
+	if calls := len(s.Requests()); calls != times {
+		return fmt.Errorf("expected stub to be called %d times, but it was called %d times", times, calls)
+	}
+	return nil
+}
+
+// AssertNotCalled returns an error if the stub answered any request
+func (s *MockStub) AssertNotCalled() error {
+	return s.AssertCalled(0)
+}
+
+// accept claims the stub for request if it matches and has not been used up
+func (s *MockStub) accept(request *http.Request) (MockResponder, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.remaining == 0 {
+		return nil, false
+	}
+	for _, matcher := range s.matchers {
+		if !matcher(request) {
+			return nil, false
+		}
+	}
+	if s.remaining > 0 {
+		s.remaining--
+	}
+	s.requests = append(s.requests, request)
+	return s.responder, true
+}
+
 // NewMockHTTPClient creates a new instance of MockHTTPClient
 func NewMockHTTPClient() *MockHTTPClient {
 	return &MockHTTPClient{
@@ -21,8 +94,48 @@ func NewMockHTTPClient() *MockHTTPClient {
 	}
 }
 
+// NewCassetteMockHTTPClient creates a mock client that records real exchanges into a cassette file or replays
+// them from it, depending on the cassette mode
+func NewCassetteMockHTTPClient(options *CassetteOptions) (*MockHTTPClient, error) {
+	player, err := newCassettePlayer(options)
+	if err != nil {
+		return nil, err
+	}
+	m := NewMockHTTPClient()
+	m.cassette = player
+	return m, nil
+}
+
+// SaveCassette writes the recorded exchanges to the cassette file
+func (m *MockHTTPClient) SaveCassette() error {
+	if m.cassette == nil {
+		return errors.New("mock client is not in cassette mode")
+	}
+	if m.cassette.opts.Mode != CassetteModeRecord {
+		return errors.New("cassette is not in record mode")
+	}
+	return m.cassette.save()
+}
+
 // DoRequest returns a predefined response based on the request and its invocation count
 func (m *MockHTTPClient) DoRequest(request *http.Request) (*Response, error) {
+	m.mutex.Lock()
+	m.requests = append(m.requests, request)
+	stubs := append([]*MockStub(nil), m.stubs...)
+	m.mutex.Unlock()
+
+	for _, stub := range stubs {
+		if responder, ok := stub.accept(request); ok {
+			if responder == nil {
+				return nil, errors.New("stub has no response")
+			}
+			return responder(request)
+		}
+	}
+	if m.cassette != nil {
+		return m.cassette.handle(request)
+	}
+
 	m.mutex.Lock()
 	key := m.generateRequestKey(request)
 	count := m.requestCount[key]
@@ -78,6 +191,11 @@ func (m *MockHTTPClient) Stop() {
 	// No-op
 }
 
+// Stats reports no lanes for the mock client
+func (m *MockHTTPClient) Stats() ClientStats {
+	return ClientStats{}
+}
+
 // Verbose is a no-op for the mock client
 func (m *MockHTTPClient) Verbose(use bool) {
 	// No-op
@@ -103,16 +221,55 @@ func (m *MockHTTPClient) SetResponsesForRequest(request *http.Request, responses
 	m.requestCount[key] = 0
 }
 
+// When registers a stub for requests matching all matchers, stubs take precedence over responses set by request
+func (m *MockHTTPClient) When(matchers ...RequestMatcher) *MockStub {
+	stub := &MockStub{matchers: matchers, remaining: -1}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stubs = append(m.stubs, stub)
+	return stub
+}
+
+// Requests returns every request received by the mock in arrival order
+func (m *MockHTTPClient) Requests() []*http.Request {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]*http.Request(nil), m.requests...)
+}
+
+// AssertCalled returns an error unless exactly times received requests match all matchers
+func (m *MockHTTPClient) AssertCalled(times int, matchers ...RequestMatcher) error {
+	calls := 0
+	for _, request := range m.Requests() {
+		matched := true
+		for _, matcher := range matchers {
+			if !matcher(request) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			calls++
+		}
+	}
+	if calls != times {
+		return fmt.Errorf("expected %d matching requests, got %d", times, calls)
+	}
+	return nil
+}
+
 // generateRequestKey creates a unique key for a request based on its method and URL
 func (m *MockHTTPClient) generateRequestKey(request *http.Request) string {
 	return request.Method + ":" + request.URL.String()
 }
 
-// Reset clears all configured responses and request counts
+// Reset clears all configured responses, stubs, captured requests and request counts
 func (m *MockHTTPClient) Reset() {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	m.responses = make(map[string][]*Response)
 	m.requestCount = make(map[string]int)
+	m.stubs = nil
+	m.requests = nil
 }
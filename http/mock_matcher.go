@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestMatcher decides whether a MockStub applies to a request
+type RequestMatcher func(request *http.Request) bool
+
+// MockResponder produces the response of a MockStub, returning an error simulates a failed request
+type MockResponder func(request *http.Request) (*Response, error)
+
+func MatchMethod(method string) RequestMatcher {
+	return func(request *http.Request) bool {
+		return strings.EqualFold(request.Method, method)
+	}
+}
+
+// MatchURL matches the full request URL including the query string
+func MatchURL(url string) RequestMatcher {
+	return func(request *http.Request) bool {
+		return request.URL.String() == url
+	}
+}
+
+func MatchPath(path string) RequestMatcher {
+	return func(request *http.Request) bool {
+		return request.URL.Path == path
+	}
+}
+
+func MatchHeader(key, value string) RequestMatcher {
+	return func(request *http.Request) bool {
+		for _, v := range request.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func MatchQueryParam(key, value string) RequestMatcher {
+	return func(request *http.Request) bool {
+		for _, v := range request.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchBodyRegex matches request bodies against pattern, it panics if pattern is not a valid regexp
+func MatchBodyRegex(pattern string) RequestMatcher {
+	re := regexp.MustCompile(pattern)
+	return func(request *http.Request) bool {
+		body, err := snapshotRequestBody(request)
+		return err == nil && re.Match(body.Bytes())
+	}
+}
+
+// MatchJSONPath matches JSON bodies whose value at path equals expected. Paths are dot separated with
+// numeric segments indexing arrays, e.g. "items.0.name" or "$.items[0].name"
+func MatchJSONPath(path string, expected interface{}) RequestMatcher {
+	segments := parseJSONPath(path)
+	expectedValue, ok := normalizeJSONValue(expected)
+	return func(request *http.Request) bool {
+		if !ok {
+			return false
+		}
+		body, err := snapshotRequestBody(request)
+		if err != nil {
+			return false
+		}
+		var document interface{}
+		if err = json.Unmarshal(body.Bytes(), &document); err != nil {
+			return false
+		}
+		actual, found := lookupJSONPath(document, segments)
+		return found && reflect.DeepEqual(actual, expectedValue)
+	}
+}
+
+// MatchPredicate wraps an arbitrary predicate, it exists for readability next to the other matchers
+func MatchPredicate(predicate func(request *http.Request) bool) RequestMatcher {
+	return predicate
+}
+
+func parseJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// normalizeJSONValue converts value into the representation produced by json.Unmarshal into interface{}
+func normalizeJSONValue(value interface{}) (interface{}, bool) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	var normalized interface{}
+	return normalized, json.Unmarshal(data, &normalized) == nil
+}
+
+func lookupJSONPath(document interface{}, segments []string) (interface{}, bool) {
+	current := document
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// RespondWith always answers with resp
+func RespondWith(resp *Response) MockResponder {
+	return func(*http.Request) (*Response, error) {
+		return resp, nil
+	}
+}
+
+// RespondWithError always fails with err
+func RespondWithError(err error) MockResponder {
+	return func(*http.Request) (*Response, error) {
+		return nil, err
+	}
+}
+
+// RespondWithDelay delays responder by delay, returning early with the context error if the request is cancelled
+func RespondWithDelay(delay time.Duration, responder MockResponder) MockResponder {
+	return func(request *http.Request) (*Response, error) {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return responder(request)
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+}
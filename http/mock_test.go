@@ -1,8 +1,12 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMockHTTPClient_SingleResponse(t *testing.T) {
@@ -118,3 +122,72 @@ func TestMockHTTPClient_UnconfiguredRequest(t *testing.T) {
 		t.Errorf("Expected error message: %s, but got: %s", "no predefined response for request", err.Error())
 	}
 }
+
+func TestMockHTTPClient_StubMatchers(t *testing.T) {
+	client := NewMockHTTPClient()
+	jsonStub := client.When(
+		MatchMethod("POST"),
+		MatchPath("/users"),
+		MatchHeader("Content-Type", "application/json"),
+		MatchQueryParam("dry_run", "true"),
+		MatchJSONPath("$.user.tags[1]", "admin"),
+	).Respond(&Response{Code: 201, Body: []byte("created")})
+	regexStub := client.When(MatchBodyRegex(`^hello \d+$`)).Respond(&Response{Code: 200, Body: []byte("regex")})
+
+	req, _ := http.NewRequest("POST", "http://example.com/users?dry_run=true", strings.NewReader(`{"user":{"tags":["dev","admin"]}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.DoRequest(req)
+	if err != nil || resp.Code != 201 {
+		t.Fatalf("expected JSON stub to answer, got %+v, %v", resp, err)
+	}
+
+	regexReq, _ := http.NewRequest("PUT", "http://example.com/other", strings.NewReader("hello 42"))
+	resp, err = client.DoRequest(regexReq)
+	if err != nil || string(resp.Body) != "regex" {
+		t.Fatalf("expected regex stub to answer, got %+v, %v", resp, err)
+	}
+
+	unmatched, _ := http.NewRequest("POST", "http://example.com/users", strings.NewReader(`{"user":{"tags":["dev"]}}`))
+	if _, err = client.DoRequest(unmatched); err == nil {
+		t.Error("expected unmatched request to fail")
+	}
+
+	if err = jsonStub.AssertCalled(1); err != nil {
+		t.Error(err)
+	}
+	if err = regexStub.AssertCalled(1); err != nil {
+		t.Error(err)
+	}
+	if err = client.AssertCalled(2, MatchMethod("POST")); err != nil {
+		t.Error(err)
+	}
+	if requests := client.Requests(); len(requests) != 3 || requests[1] != regexReq {
+		t.Errorf("expected all requests to be captured in order, got %d", len(requests))
+	}
+}
+
+func TestMockHTTPClient_DynamicResponders(t *testing.T) {
+	client := NewMockHTTPClient()
+	injected := errors.New("connection reset")
+	client.When(MatchPath("/flaky")).Times(1).RespondWith(RespondWithError(injected))
+	client.When(MatchPath("/flaky")).RespondWith(func(request *http.Request) (*Response, error) {
+		return &Response{Code: 200, Body: []byte(request.URL.Query().Get("id"))}, nil
+	})
+	client.When(MatchPath("/slow")).RespondWith(RespondWithDelay(time.Second, RespondWith(&Response{Code: 200})))
+
+	req, _ := http.NewRequest("GET", "http://example.com/flaky?id=7", nil)
+	if _, err := client.DoRequest(req); !errors.Is(err, injected) {
+		t.Fatalf("expected injected error first, got %v", err)
+	}
+	resp, err := client.DoRequest(req)
+	if err != nil || string(resp.Body) != "7" {
+		t.Fatalf("expected dynamic response after the error, got %+v, %v", resp, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	slowReq, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/slow", nil)
+	if _, err = client.DoRequest(slowReq); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected delayed responder to honor the request context, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	ContentTypeForm        = "application/x-www-form-urlencoded"
+	ContentTypeOctetStream = "application/octet-stream"
+)
+
+type multipartPart struct {
+	field    string
+	value    string
+	filename string
+	// open is set for file parts, it is called every time the body is written so uploads can be replayed
+	open func() (io.ReadCloser, error)
+}
+
+type multipartForm struct {
+	boundary string
+	parts    []multipartPart
+}
+
+// MultipartForm describes a multipart/form-data body, parts are written in the order they are added. File parts
+// are streamed from their source when the request is sent rather than buffered in memory.
+type MultipartForm interface {
+	Field(name, value string) MultipartForm
+	// File adds a file part, open is called each time the body is sent, including retries.
+	File(field, filename string, open func() (io.ReadCloser, error)) MultipartForm
+	// FilePath adds a file part read from path.
+	FilePath(field, path string) MultipartForm
+	// ContentType is the multipart/form-data content type with the boundary of the form.
+	ContentType() string
+	// Reader returns a new reader of the encoded form, the content is produced as it is read.
+	Reader() io.ReadCloser
+}
+
+func NewMultipartForm() MultipartForm {
+	return &multipartForm{boundary: multipart.NewWriter(io.Discard).Boundary()}
+}
+
+func (f *multipartForm) Field(name, value string) MultipartForm {
+	f.parts = append(f.parts, multipartPart{field: name, value: value})
+	return f
+}
+
+func (f *multipartForm) File(field, filename string, open func() (io.ReadCloser, error)) MultipartForm {
+	f.parts = append(f.parts, multipartPart{field: field, filename: filename, open: open})
+	return f
+}
+
+func (f *multipartForm) FilePath(field, path string) MultipartForm {
+	return f.File(field, filepath.Base(path), func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+func (f *multipartForm) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+func (f *multipartForm) Reader() io.ReadCloser {
+	return &multipartReader{form: f, mutex: new(sync.Mutex)}
+}
+
+func (f *multipartForm) writeTo(w io.Writer) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(f.boundary); err != nil {
+		return err
+	}
+	for _, part := range f.parts {
+		if part.open == nil {
+			if err := writer.WriteField(part.field, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeMultipartFile(writer, part); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+func writeMultipartFile(writer *multipart.Writer, part multipartPart) error {
+	file, err := part.open()
+	if err != nil {
+		return fmt.Errorf("open multipart file %s: %w", part.filename, err)
+	}
+	defer file.Close()
+	contentType := mime.TypeByExtension(filepath.Ext(part.filename))
+	if contentType == "" {
+		contentType = ContentTypeOctetStream
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeMultipartQuotes(part.field), escapeMultipartQuotes(part.filename)))
+	header.Set("Content-Type", contentType)
+	w, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+var multipartQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func escapeMultipartQuotes(s string) string {
+	return multipartQuoteEscaper.Replace(s)
+}
+
+// multipartReader encodes the form through a pipe, the encoding goroutine only starts on the first read so
+// bodies that are built but never sent hold no goroutine nor open files.
+type multipartReader struct {
+	form      *multipartForm
+	startOnce sync.Once
+	pipe      *io.PipeReader
+	mutex     *sync.Mutex
+	closed    bool
+}
+
+func (r *multipartReader) start() {
+	r.startOnce.Do(func() {
+		reader, writer := io.Pipe()
+		r.mutex.Lock()
+		r.pipe = reader
+		closed := r.closed
+		r.mutex.Unlock()
+		if closed {
+			reader.Close()
+			return
+		}
+		go func() {
+			writer.CloseWithError(r.form.writeTo(writer))
+		}()
+	})
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.start()
+	return r.pipe.Read(p)
+}
+
+func (r *multipartReader) Close() error {
+	r.mutex.Lock()
+	r.closed = true
+	pipe := r.pipe
+	r.mutex.Unlock()
+	if pipe != nil {
+		return pipe.Close()
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFormBodySetsContentType(t *testing.T) {
+	header := http.Header{"X-Custom": {"1"}}
+	req, err := NewRequestBuilder().Method(http.MethodPost).URL("http://example.com").Header(header).
+		FormBody(url.Values{"name": {"gommon"}, "tags": {"a", "b"}}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != ContentTypeForm {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	if header.Get("Content-Type") != "" {
+		t.Errorf("expected the builder not to mutate the caller's header")
+	}
+	if err := req.ParseForm(); err != nil || req.PostForm.Get("name") != "gommon" || len(req.PostForm["tags"]) != 2 {
+		t.Errorf("unexpected form %v, %v", req.PostForm, err)
+	}
+
+	req, _ = NewRequestBuilder().Method(http.MethodPost).URL("http://example.com").
+		FormBody(url.Values{"a": {"b"}}).StringBody("raw").Build()
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		t.Errorf("expected a raw body to drop the form content type, got %q", contentType)
+	}
+}
+
+func TestMultipartBodyStreamsFilesAndReplaysOnRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("id,name\n1,gommon\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse multipart form: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		file, header, err := r.FormFile("report")
+		if err != nil {
+			t.Errorf("missing file part: %v", err)
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		inline, inlineHeader, _ := r.FormFile("notes")
+		inlineContent, _ := io.ReadAll(inline)
+		io.WriteString(w, strings.Join([]string{
+			r.FormValue("title"),
+			header.Filename,
+			header.Header.Get("Content-Type"),
+			string(content),
+			inlineHeader.Filename,
+			string(inlineContent),
+		}, "|"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(RetryPolicyInterceptor(&RetryPolicy{BaseDelay: time.Millisecond, RetryNonIdempotent: true})).Build()
+	defer c.Stop()
+
+	var opened int32
+	form := NewMultipartForm().
+		Field("title", "monthly \"report\"").
+		FilePath("report", path).
+		File("notes", "notes.bin", func() (io.ReadCloser, error) {
+			atomic.AddInt32(&opened, 1)
+			return io.NopCloser(strings.NewReader("raw notes")), nil
+		})
+	req, err := NewRequestBuilder().Method(http.MethodPost).URL(ts.URL).MultipartBody(form).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != form.ContentType() || !strings.HasPrefix(contentType, "multipart/form-data; boundary=") {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("unexpected result %v, %v", resp, err)
+	}
+	expected := "monthly \"report\"|report.csv|text/csv; charset=utf-8|id,name\n1,gommon\n|notes.bin|raw notes"
+	if string(resp.Body) != expected {
+		t.Errorf("unexpected echo %q", resp.Body)
+	}
+	if hits != 2 || atomic.LoadInt32(&opened) != 2 {
+		t.Errorf("expected the upload to be replayed once, got %d hits and %d opens", hits, opened)
+	}
+}
@@ -0,0 +1,411 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const HeaderLink = "Link"
+
+// PageRequest describes a page to fetch. Strategies keep URL pointing at the page, Cursor, Offset and Limit are
+// also set for fetchers that send them some other way.
+type PageRequest struct {
+	// Index is the zero-based number of the page.
+	Index  int
+	URL    string
+	Cursor string
+	Offset int
+	Limit  int
+}
+
+type Page[T any] struct {
+	Request PageRequest
+	Items   []T
+	// Response is the response the page was decoded from, strategies read the next page from it.
+	Response *Response
+}
+
+type PageFetcher[T any] func(ctx context.Context, request PageRequest) (*Page[T], error)
+
+// PageStrategy finds the pages following the first one.
+type PageStrategy[T any] interface {
+	// First completes the request of the first page.
+	First(request PageRequest) PageRequest
+	// Next returns the request of the page after page, false if page is the last one.
+	Next(current PageRequest, page *Page[T]) (PageRequest, bool)
+}
+
+// pagePredictor is implemented by strategies that know the next request without fetching the current page, the
+// pager fetches their pages concurrently.
+type pagePredictor interface {
+	predict(current PageRequest) PageRequest
+}
+
+// pageStrategyValidator is implemented by strategies that can be misconfigured, the pager reports the error
+// instead of fetching pages.
+type pageStrategyValidator interface {
+	validate() error
+}
+
+// NextPageFunc is a PageStrategy from a next-page extractor.
+type NextPageFunc[T any] func(current PageRequest, page *Page[T]) (PageRequest, bool)
+
+func (f NextPageFunc[T]) First(request PageRequest) PageRequest {
+	return request
+}
+
+func (f NextPageFunc[T]) Next(current PageRequest, page *Page[T]) (PageRequest, bool) {
+	return f(current, page)
+}
+
+type PagerOptions struct {
+	// MaxPages stops the pager after that many pages, unlimited if 0.
+	MaxPages int
+	// Concurrency is the number of pages fetched ahead for strategies that can tell the next page without the
+	// current one, such as OffsetPages. Pages are still yielded in order.
+	Concurrency int
+}
+
+type Pager[T any] struct {
+	fetch    PageFetcher[T]
+	strategy PageStrategy[T]
+	options  PagerOptions
+}
+
+func NewPager[T any](fetch PageFetcher[T], strategy PageStrategy[T], opts *PagerOptions) *Pager[T] {
+	options := PagerOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	return &Pager[T]{fetch: fetch, strategy: strategy, options: options}
+}
+
+// Pages iterates over the pages starting at url, iteration stops at the first error.
+func (p *Pager[T]) Pages(ctx context.Context, url string) iter.Seq2[*Page[T], error] {
+	return func(yield func(*Page[T], error) bool) {
+		if validator, ok := p.strategy.(pageStrategyValidator); ok {
+			if err := validator.validate(); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		first := p.strategy.First(PageRequest{URL: url})
+		if predictor, ok := p.strategy.(pagePredictor); ok && p.options.Concurrency > 1 {
+			p.prefetchPages(ctx, first, predictor, yield)
+			return
+		}
+		request := first
+		for p.options.MaxPages <= 0 || request.Index < p.options.MaxPages {
+			page, err := p.fetchPage(ctx, request)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(page, nil) {
+				return
+			}
+			next, ok := p.strategy.Next(request, page)
+			if !ok {
+				return
+			}
+			next.Index = request.Index + 1
+			request = next
+		}
+	}
+}
+
+// Items iterates over the items of all pages starting at url, iteration stops at the first error.
+func (p *Pager[T]) Items(ctx context.Context, url string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page, err := range p.Pages(ctx, url) {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Pager[T]) fetchPage(ctx context.Context, request PageRequest) (*Page[T], error) {
+	page, err := p.fetch(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetch page %d: %w", request.Index, err)
+	}
+	page.Request = request
+	return page, nil
+}
+
+type pageResult[T any] struct {
+	page *Page[T]
+	err  error
+}
+
+// prefetchPages keeps up to Concurrency pages in flight and yields them in order, pages fetched past the last
+// one are dropped.
+func (p *Pager[T]) prefetchPages(ctx context.Context, first PageRequest, predictor pagePredictor, yield func(*Page[T], error) bool) {
+	pending := make([]chan pageResult[T], 0, p.options.Concurrency)
+	next := first
+	issue := func() {
+		if p.options.MaxPages > 0 && next.Index >= p.options.MaxPages {
+			return
+		}
+		result := make(chan pageResult[T], 1)
+		request := next
+		go func() {
+			page, err := p.fetchPage(ctx, request)
+			result <- pageResult[T]{page, err}
+		}()
+		pending = append(pending, result)
+		next = predictor.predict(request)
+		next.Index = request.Index + 1
+	}
+	for i := 0; i < p.options.Concurrency; i++ {
+		issue()
+	}
+	for len(pending) > 0 {
+		result := <-pending[0]
+		pending = pending[1:]
+		if result.err != nil {
+			yield(nil, result.err)
+			return
+		}
+		if !yield(result.page, nil) {
+			return
+		}
+		if _, ok := p.strategy.Next(result.page.Request, result.page); !ok {
+			return
+		}
+		issue()
+	}
+}
+
+// NewPageFetcher GETs the URL of each page with c and decodes its items with decode, non-2xx responses yield
+// *HTTPError.
+func NewPageFetcher[T any](c Client, decode func(resp *Response) ([]T, error), opts ...JSONRequestOpt) PageFetcher[T] {
+	return func(ctx context.Context, request PageRequest) (*Page[T], error) {
+		options := &JSONRequestOptions{Header: http.Header{}}
+		for _, opt := range opts {
+			options = opt(options)
+		}
+		req, err := NewRequestBuilder().Context(ctx).URL(request.URL).Header(options.Header.Clone()).
+			Timeout(options.Timeout).Build()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.Request(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Code < 200 || resp.Code > 299 {
+			return nil, &HTTPError{resp.Code, resp.Header, resp.Body, resp.URI}
+		}
+		items, err := decode(resp)
+		if err != nil {
+			return nil, err
+		}
+		return &Page[T]{Items: items, Response: resp}, nil
+	}
+}
+
+// JSONItems decodes the items of a page from the JSON array at the dot separated path of the response body, the
+// body itself is the array if path is empty.
+func JSONItems[T any](path string) func(resp *Response) ([]T, error) {
+	return func(resp *Response) ([]T, error) {
+		raw, ok, err := jsonAtPath(resp.Body, path)
+		if err != nil || !ok {
+			return nil, err
+		}
+		var items []T
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("decode page items: %w", err)
+		}
+		return items, nil
+	}
+}
+
+// jsonAtPath returns the raw value at the dot separated path of body, false if it is missing or null.
+func jsonAtPath(body []byte, path string) (json.RawMessage, bool, error) {
+	raw := json.RawMessage(body)
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &object); err != nil {
+				return nil, false, fmt.Errorf("decode %s of page: %w", path, err)
+			}
+			if raw = object[key]; raw == nil {
+				return nil, false, nil
+			}
+		}
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, false, nil
+	}
+	return raw, true, nil
+}
+
+type linkHeaderPages[T any] struct{}
+
+// LinkHeaderPages follows the rel="next" link of the Link header(RFC 5988) of each page.
+func LinkHeaderPages[T any]() PageStrategy[T] {
+	return linkHeaderPages[T]{}
+}
+
+func (linkHeaderPages[T]) First(request PageRequest) PageRequest {
+	return request
+}
+
+func (linkHeaderPages[T]) Next(current PageRequest, page *Page[T]) (PageRequest, bool) {
+	if page.Response == nil {
+		return PageRequest{}, false
+	}
+	link, ok := LinkHeaderURL(page.Response.Header, "next")
+	if !ok {
+		return PageRequest{}, false
+	}
+	// links may be relative to the page they were returned with
+	if base, err := url.Parse(current.URL); err == nil {
+		if resolved, err := base.Parse(link); err == nil {
+			link = resolved.String()
+		}
+	}
+	return PageRequest{URL: link}, true
+}
+
+// LinkHeaderURL returns the target of the first link with relation rel in the Link headers of header.
+func LinkHeaderURL(header http.Header, rel string) (string, bool) {
+	for _, value := range header.Values(HeaderLink) {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			end := strings.IndexByte(value, '>')
+			if start < 0 || end < start {
+				break
+			}
+			target := value[start+1 : end]
+			value = value[end+1:]
+			params := value
+			if next := strings.IndexByte(value, '<'); next >= 0 {
+				params = value[:next]
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, paramValue, found := strings.Cut(strings.TrimSpace(param), "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, relation := range strings.Fields(strings.Trim(strings.TrimSpace(paramValue), `",`)) {
+					if strings.EqualFold(relation, rel) {
+						return target, true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+type cursorPages[T any] struct {
+	field string
+	param string
+}
+
+// CursorPages reads the cursor of the next page from the JSON field at the dot separated path of each page and
+// sends it in the param query parameter, pagination ends once the cursor is missing, null or empty.
+func CursorPages[T any](field, param string) PageStrategy[T] {
+	return cursorPages[T]{field: field, param: param}
+}
+
+func (s cursorPages[T]) First(request PageRequest) PageRequest {
+	return request
+}
+
+func (s cursorPages[T]) Next(current PageRequest, page *Page[T]) (PageRequest, bool) {
+	if page.Response == nil {
+		return PageRequest{}, false
+	}
+	raw, ok, err := jsonAtPath(page.Response.Body, s.field)
+	if err != nil || !ok {
+		return PageRequest{}, false
+	}
+	var cursor string
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		// numeric cursors are sent as they are
+		cursor = string(bytes.TrimSpace(raw))
+	}
+	if cursor == "" {
+		return PageRequest{}, false
+	}
+	return PageRequest{URL: withQueryParams(current.URL, map[string]string{s.param: cursor}), Cursor: cursor}, true
+}
+
+type offsetPages[T any] struct {
+	offsetParam string
+	limitParam  string
+	limit       int
+}
+
+// OffsetPages requests pages of limit items through the offsetParam and limitParam query parameters, pagination
+// ends with the first page holding fewer than limit items. Its pages can be fetched concurrently. Iteration fails
+// without fetching a page when limit is not positive.
+func OffsetPages[T any](offsetParam, limitParam string, limit int) PageStrategy[T] {
+	return offsetPages[T]{offsetParam: offsetParam, limitParam: limitParam, limit: limit}
+}
+
+func (s offsetPages[T]) validate() error {
+	if s.limit <= 0 {
+		return fmt.Errorf("offset pages limit must be positive, got %d", s.limit)
+	}
+	return nil
+}
+
+func (s offsetPages[T]) First(request PageRequest) PageRequest {
+	request.Limit = s.limit
+	request.URL = withQueryParams(request.URL, map[string]string{
+		s.offsetParam: strconv.Itoa(request.Offset),
+		s.limitParam:  strconv.Itoa(s.limit),
+	})
+	return request
+}
+
+func (s offsetPages[T]) Next(current PageRequest, page *Page[T]) (PageRequest, bool) {
+	if len(page.Items) < s.limit {
+		return PageRequest{}, false
+	}
+	return s.predict(current), true
+}
+
+func (s offsetPages[T]) predict(current PageRequest) PageRequest {
+	next := PageRequest{Offset: current.Offset + s.limit, Limit: s.limit}
+	next.URL = withQueryParams(current.URL, map[string]string{s.offsetParam: strconv.Itoa(next.Offset)})
+	return next
+}
+
+// withQueryParams sets params in the query of rawURL, rawURL is returned as is if it does not parse.
+func withQueryParams(rawURL string, params map[string]string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newPagedServer(total int, handle func(w http.ResponseWriter, r *http.Request, items []int)) *httptest.Server {
+	items := make([]int, total)
+	for i := range items {
+		items[i] = i
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, items)
+	}))
+}
+
+func collectItems(pager *Pager[int], url string) ([]int, error) {
+	var items []int
+	for item, err := range pager.Items(context.Background(), url) {
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func TestPagerFollowsLinkHeaders(t *testing.T) {
+	ts := newPagedServer(7, func(w http.ResponseWriter, r *http.Request, items []int) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		end := min((page+1)*3, len(items))
+		if end < len(items) {
+			w.Header().Add(HeaderLink, fmt.Sprintf(`<https://example.com/first>; rel="first", </items?page=%d>; rel="next last"`, page+1))
+		}
+		json.NewEncoder(w).Encode(items[page*3 : end])
+	})
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).Build()
+	defer c.Stop()
+	pager := NewPager(NewPageFetcher(c, JSONItems[int]("")), LinkHeaderPages[int](), nil)
+	items, err := collectItems(pager, ts.URL+"/items")
+	if err != nil || fmt.Sprint(items) != "[0 1 2 3 4 5 6]" {
+		t.Errorf("unexpected items %v, %v", items, err)
+	}
+
+	limited := NewPager(NewPageFetcher(c, JSONItems[int]("")), LinkHeaderPages[int](), &PagerOptions{MaxPages: 2})
+	if items, err = collectItems(limited, ts.URL+"/items"); err != nil || len(items) != 6 {
+		t.Errorf("expected 2 pages, got %v, %v", items, err)
+	}
+}
+
+func TestPagerFollowsCursors(t *testing.T) {
+	ts := newPagedServer(5, func(w http.ResponseWriter, r *http.Request, items []int) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		end := min(start+2, len(items))
+		var cursor interface{}
+		if end < len(items) {
+			cursor = strconv.Itoa(end)
+		}
+		if start >= len(items) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": items[start:end],
+			"meta": map[string]interface{}{"next_cursor": cursor},
+		})
+	})
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).Build()
+	defer c.Stop()
+	var pages []PageRequest
+	pager := NewPager(NewPageFetcher(c, JSONItems[int]("data")), CursorPages[int]("meta.next_cursor", "after"), nil)
+	for page, err := range pager.Pages(context.Background(), ts.URL+"/items?sort=asc") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, page.Request)
+	}
+	if len(pages) != 3 || pages[2].Index != 2 || pages[2].Cursor != "4" || pages[2].URL != ts.URL+"/items?after=4&sort=asc" {
+		t.Errorf("unexpected pages %+v", pages)
+	}
+
+	failing := NewPager(NewPageFetcher(c, JSONItems[int]("data")), NextPageFunc[int](func(current PageRequest, page *Page[int]) (PageRequest, bool) {
+		return PageRequest{URL: withQueryParams(current.URL, map[string]string{"after": "9"})}, true
+	}), nil)
+	items, err := collectItems(failing, ts.URL+"/items")
+	var httpErr *HTTPError
+	if len(items) != 2 || !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("expected the first page and then the error, got %v, %v", items, err)
+	}
+}
+
+func TestPagerFetchesOffsetPagesConcurrently(t *testing.T) {
+	var inFlight, maxInFlight, requests, stoppedRequests int32
+	ts := newPagedServer(23, func(w http.ResponseWriter, r *http.Request, items []int) {
+		if r.URL.Query().Get("run") == "stopped" {
+			atomic.AddInt32(&stoppedRequests, 1)
+		} else {
+			atomic.AddInt32(&requests, 1)
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			current := atomic.LoadInt32(&maxInFlight)
+			if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start := min(offset, len(items))
+		json.NewEncoder(w).Encode(items[start:min(start+limit, len(items))])
+	})
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(4).TimeoutSec(5).Build()
+	defer c.Stop()
+	pager := NewPager(NewPageFetcher(c, JSONItems[int]("")), OffsetPages[int]("offset", "limit", 5), &PagerOptions{Concurrency: 3})
+	items, err := collectItems(pager, ts.URL+"/items")
+	if err != nil || len(items) != 23 {
+		t.Fatalf("unexpected items %v, %v", items, err)
+	}
+	for i, item := range items {
+		if item != i {
+			t.Fatalf("expected items in order, got %v", items)
+		}
+	}
+	if n := atomic.LoadInt32(&maxInFlight); n < 2 || n > 3 {
+		t.Errorf("expected up to 3 pages in flight, got %d", n)
+	}
+	// 5 pages hold the items, at most 2 more were fetched ahead of the last one
+	if n := atomic.LoadInt32(&requests); n < 5 || n > 7 {
+		t.Errorf("unexpected number of page requests %d", n)
+	}
+
+	// stopping early does not fetch the remaining pages
+	for item := range pager.Items(context.Background(), ts.URL+"/items?run=stopped") {
+		if item == 2 {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&stoppedRequests); n > 3 {
+		t.Errorf("expected no pages to be fetched after the iteration stopped, got %d requests", n)
+	}
+}
+
+func TestOffsetPagesRejectsNonPositiveLimits(t *testing.T) {
+	var requests int32
+	ts := newPagedServer(3, func(w http.ResponseWriter, r *http.Request, items []int) {
+		atomic.AddInt32(&requests, 1)
+		json.NewEncoder(w).Encode(items)
+	})
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).Build()
+	defer c.Stop()
+	for _, limit := range []int{0, -1} {
+		for _, concurrency := range []int{1, 3} {
+			pager := NewPager(NewPageFetcher(c, JSONItems[int]("")), OffsetPages[int]("offset", "limit", limit),
+				&PagerOptions{Concurrency: concurrency})
+			if items, err := collectItems(pager, ts.URL+"/items"); err == nil || len(items) != 0 {
+				t.Errorf("limit %d: expected an error and no items, got %v, %v", limit, items, err)
+			}
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("expected no page to be fetched, got %d requests", n)
+	}
+}
+
+func TestLinkHeaderURL(t *testing.T) {
+	header := http.Header{}
+	header.Add(HeaderLink, `<https://api.example.com/items?page=1>; rel="prev"`)
+	header.Add(HeaderLink, `<https://api.example.com/items?page=3&per_page=10>;rel=next, <https://api.example.com/items?page=9>; rel="last"`)
+	if link, ok := LinkHeaderURL(header, "next"); !ok || link != "https://api.example.com/items?page=3&per_page=10" {
+		t.Errorf("unexpected next link %q", link)
+	}
+	if link, ok := LinkHeaderURL(header, "LAST"); !ok || link != "https://api.example.com/items?page=9" {
+		t.Errorf("unexpected last link %q", link)
+	}
+	if _, ok := LinkHeaderURL(header, "first"); ok {
+		t.Errorf("expected no first link")
+	}
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DefaultRedactedValue = "[REDACTED]"
+
+// RedactionRules decide what is masked before requests and responses are logged or recorded, names are matched
+// case-insensitively.
+type RedactionRules struct {
+	Headers []string
+	// Fields are masked in JSON bodies at any depth, and in query parameters and form bodies.
+	Fields []string
+	// Replacement replaces redacted values, DefaultRedactedValue if empty.
+	Replacement string
+}
+
+// DefaultRedactionRules masks credentials headers along with token, password and secret fields.
+func DefaultRedactionRules() *RedactionRules {
+	return &RedactionRules{
+		Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Amz-Security-Token"},
+		Fields: []string{"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token",
+			"id_token", "api_key", "apikey"},
+		Replacement: DefaultRedactedValue,
+	}
+}
+
+type redactor struct {
+	headers     map[string]bool
+	fields      map[string]bool
+	replacement string
+}
+
+func newRedactor(rules *RedactionRules) *redactor {
+	if rules == nil {
+		rules = DefaultRedactionRules()
+	}
+	r := &redactor{headers: make(map[string]bool), fields: make(map[string]bool), replacement: rules.Replacement}
+	if r.replacement == "" {
+		r.replacement = DefaultRedactedValue
+	}
+	for _, header := range rules.Headers {
+		r.headers[strings.ToLower(header)] = true
+	}
+	for _, field := range rules.Fields {
+		r.fields[strings.ToLower(field)] = true
+	}
+	return r
+}
+
+func (r *redactor) header(key, value string) string {
+	if r.headers[strings.ToLower(key)] {
+		return r.replacement
+	}
+	return value
+}
+
+// redactHeader returns a copy of header with the values of sensitive headers masked.
+func (r *redactor) redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key, values := range redacted {
+		if r.headers[strings.ToLower(key)] {
+			for i := range values {
+				values[i] = r.replacement
+			}
+		}
+	}
+	return redacted
+}
+
+func (r *redactor) url(u *url.URL) string {
+	redacted := *u
+	redacted.RawQuery = r.query(u.RawQuery)
+	return redacted.Redacted()
+}
+
+func (r *redactor) query(rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+	// pairs that fail to parse, such as one cut by a truncated body, are dropped rather than echoed unmasked
+	values, err := url.ParseQuery(rawQuery)
+	redacted := err != nil
+	for key := range values {
+		if r.fields[strings.ToLower(key)] {
+			values[key] = []string{r.replacement}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// body masks fields of JSON and form bodies, other bodies are returned as is.
+func (r *redactor) body(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	if strings.HasPrefix(contentType, ContentTypeForm) {
+		return []byte(r.query(string(body)))
+	}
+	trimmed := bytes.TrimSpace(body)
+	if !strings.Contains(contentType, "json") && (len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[')) {
+		return body
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return r.jsonPrefix(body)
+	}
+	if !r.redactJSON(value) {
+		return body
+	}
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// jsonFrame is an array or object a JSON token is nested in.
+type jsonFrame struct {
+	object bool
+	// keyNext is set when the next token of an object is a key
+	keyNext bool
+}
+
+// jsonPrefix masks fields of a JSON body that does not parse, such as one cut to the dump size. Values of
+// sensitive fields are masked up to where the body ends, whatever follows the last valid token is dropped.
+func (r *redactor) jsonPrefix(body []byte) []byte {
+	replacement, _ := json.Marshal(r.replacement)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var redacted bytes.Buffer
+	copied := int64(0)
+	var frames []jsonFrame
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch token {
+		case json.Delim('{'):
+			frames = append(frames, jsonFrame{object: true, keyNext: true})
+			continue
+		case json.Delim('['):
+			frames = append(frames, jsonFrame{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			frames = frames[:len(frames)-1]
+		default:
+			if key, ok := token.(string); ok && len(frames) > 0 && frames[len(frames)-1].keyNext {
+				frames[len(frames)-1].keyNext = false
+				if !r.fields[strings.ToLower(key)] {
+					continue
+				}
+				// the colon and the value are replaced, up to the end of the body if the value is cut
+				offset := decoder.InputOffset()
+				redacted.Write(body[copied:offset])
+				redacted.WriteString(":")
+				redacted.Write(replacement)
+				if !skipJSONValue(decoder) {
+					return redacted.Bytes()
+				}
+				copied = decoder.InputOffset()
+			}
+		}
+		// a value ended, the object it belongs to expects a key next
+		if len(frames) > 0 && frames[len(frames)-1].object {
+			frames[len(frames)-1].keyNext = true
+		}
+	}
+	return append(redacted.Bytes(), body[copied:decoder.InputOffset()]...)
+}
+
+// skipJSONValue reads the next value of decoder, it reports false when the value is invalid or cut.
+func skipJSONValue(decoder *json.Decoder) bool {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return true
+		}
+	}
+}
+
+func (r *redactor) redactJSON(value interface{}) (redacted bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.fields[strings.ToLower(key)] {
+				v[key] = r.replacement
+				redacted = true
+			} else if r.redactJSON(field) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if r.redactJSON(item) {
+				redacted = true
+			}
+		}
+	}
+	return
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -26,6 +27,14 @@ func requestTimeoutContextValue(ctx context.Context) (time.Duration, bool) {
 	return d, ok
 }
 
+// streamResponseKey marks requests whose response body should be handed to the caller as a stream.
+type streamResponseKey struct{}
+
+func isStreamResponseRequested(ctx context.Context) bool {
+	stream, _ := ctx.Value(streamResponseKey{}).(bool)
+	return stream
+}
+
 // HTTP Header
 type headerMaker struct {
 	header http.Header
@@ -67,7 +76,10 @@ type requestBuilder struct {
 	url        string
 	header     http.Header
 	bodyGetter func() (io.ReadCloser, error)
-	timeout    time.Duration
+	// contentType is set by the body methods that know the encoding of the body
+	contentType string
+	timeout     time.Duration
+	stream      bool
 }
 
 type RequestBuilder interface {
@@ -76,12 +88,20 @@ type RequestBuilder interface {
 	// this will set timeout context when the request is handled(not built)
 	// timeout set by this method will not be applied to the net/http http client
 	Timeout(timeout time.Duration) RequestBuilder
+	// Stream hands the response body to the caller as Response.Stream instead of buffering it into
+	// Response.Body; the caller must close the stream
+	Stream(stream bool) RequestBuilder
 	Method(method string) RequestBuilder
 	URL(url string) RequestBuilder
 	Header(header http.Header) RequestBuilder
 	Body(body io.ReadCloser) RequestBuilder
 	BytesBody(body []byte) RequestBuilder
 	StringBody(body string) RequestBuilder
+	// FormBody sends values url encoded with the application/x-www-form-urlencoded content type.
+	FormBody(values url.Values) RequestBuilder
+	// MultipartBody sends form as multipart/form-data with its boundary in the content type, file parts are
+	// streamed and reopened when the request is retried.
+	MultipartBody(form MultipartForm) RequestBuilder
 }
 
 func NewRequestBuilder() RequestBuilder {
@@ -111,10 +131,21 @@ func (b *requestBuilder) build() (*http.Request, error) {
 		return nil, err
 	}
 	req.Header = b.header
+	if b.contentType != "" {
+		// do not mutate the header passed to the builder
+		req.Header = b.header.Clone()
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+		req.Header.Set("Content-Type", b.contentType)
+	}
 	req.GetBody = b.bodyGetter
 	if b.timeout > 0 {
 		req = req.WithContext(context.WithValue(req.Context(), requestTimeoutKey{}, b.timeout))
 	}
+	if b.stream {
+		req = req.WithContext(context.WithValue(req.Context(), streamResponseKey{}, true))
+	}
 	return req, nil
 }
 
@@ -123,6 +154,11 @@ func (b *requestBuilder) Timeout(timeout time.Duration) RequestBuilder {
 	return b
 }
 
+func (b *requestBuilder) Stream(stream bool) RequestBuilder {
+	b.stream = stream
+	return b
+}
+
 func (b *requestBuilder) Context(ctx context.Context) RequestBuilder {
 	b.ctx = ctx
 	return b
@@ -144,6 +180,7 @@ func (b *requestBuilder) Header(header http.Header) RequestBuilder {
 }
 
 func (b *requestBuilder) Body(body io.ReadCloser) RequestBuilder {
+	b.contentType = ""
 	if body == nil {
 		b.bodyGetter = func() (io.ReadCloser, error) {
 			return http.NoBody, nil
@@ -166,6 +203,7 @@ func (b *requestBuilder) Body(body io.ReadCloser) RequestBuilder {
 }
 
 func (b *requestBuilder) BytesBody(body []byte) RequestBuilder {
+	b.contentType = ""
 	b.bodyGetter = func() (io.ReadCloser, error) {
 		return io.NopCloser(bytes.NewReader(body)), nil
 	}
@@ -173,8 +211,23 @@ func (b *requestBuilder) BytesBody(body []byte) RequestBuilder {
 }
 
 func (b *requestBuilder) StringBody(body string) RequestBuilder {
+	b.contentType = ""
 	b.bodyGetter = func() (io.ReadCloser, error) {
 		return io.NopCloser(strings.NewReader(body)), nil
 	}
 	return b
 }
+
+func (b *requestBuilder) FormBody(values url.Values) RequestBuilder {
+	b.StringBody(values.Encode())
+	b.contentType = ContentTypeForm
+	return b
+}
+
+func (b *requestBuilder) MultipartBody(form MultipartForm) RequestBuilder {
+	b.bodyGetter = func() (io.ReadCloser, error) {
+		return form.Reader(), nil
+	}
+	b.contentType = form.ContentType()
+	return b
+}
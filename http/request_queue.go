@@ -0,0 +1,71 @@
+package http
+
+import (
+	"container/list"
+	"sync"
+)
+
+// requestQueue is a bounded FIFO of pending requests. Unlike a channel, a request can be removed before a
+// worker picks it up, so requests whose context is done give their slot back right away.
+type requestQueue struct {
+	mutex   *sync.Mutex
+	items   *list.List
+	maxSize int
+	// ready holds at least one token per queued request; workers wait on it and then poll.
+	ready chan struct{}
+}
+
+func newRequestQueue(maxSize int) *requestQueue {
+	return &requestQueue{
+		mutex:   new(sync.Mutex),
+		items:   list.New(),
+		maxSize: maxSize,
+		ready:   make(chan struct{}, maxSize),
+	}
+}
+
+func (q *requestQueue) offer(request *trackableRequest) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.items.Len() >= q.maxSize {
+		return false
+	}
+	request.queueElement = q.items.PushBack(request)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+		// stale tokens of removed requests already cover this request
+	}
+	return true
+}
+
+// poll takes the oldest request, nil if the queue is empty.
+func (q *requestQueue) poll() *trackableRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	front := q.items.Front()
+	if front == nil {
+		return nil
+	}
+	request := q.items.Remove(front).(*trackableRequest)
+	request.queueElement = nil
+	return request
+}
+
+// remove drops a request that has not been picked up by a worker yet.
+func (q *requestQueue) remove(request *trackableRequest) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if request.queueElement == nil {
+		return false
+	}
+	q.items.Remove(request.queueElement)
+	request.queueElement = nil
+	return true
+}
+
+func (q *requestQueue) size() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.items.Len()
+}
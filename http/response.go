@@ -15,6 +15,18 @@ type Response struct {
 	Header http.Header // usage just like map, can for each kv or ["headerKey"] gives an array of strings
 	Body   []byte
 	URI    string
+	// Stream is set instead of Body for streaming requests(see RequestBuilder.Stream), it must be closed
+	Stream io.ReadCloser
+	// Timings of the attempt that produced the response, only set by clients built with ConnectionTimings
+	Timings *ConnectionTimings
+}
+
+// Close releases the response stream, it is a no-op for buffered responses.
+func (r *Response) Close() error {
+	if r == nil || r.Stream == nil {
+		return nil
+	}
+	return r.Stream.Close()
 }
 
 // response util
@@ -37,7 +49,66 @@ func fromRawResponse(resp *http.Response, maxBodySize int64) (*Response, error)
 	} else {
 		body, err = io.ReadAll(resp.Body)
 	}
-	return &Response{statusCode, resp.Header, body, uri}, err
+	return &Response{Code: statusCode, Header: resp.Header, Body: body, URI: uri}, err
+}
+
+// fromRawStreamResponse keeps the body open for the caller.
+func fromRawStreamResponse(resp *http.Response, maxBodySize int64) *Response {
+	return &Response{
+		Code:   resp.StatusCode,
+		Header: resp.Header,
+		URI:    resp.Request.URL.Path,
+		Stream: &responseStream{body: resp.Body, maxSize: maxBodySize, remaining: maxBodySize},
+	}
+}
+
+type responseStream struct {
+	body      io.ReadCloser
+	maxSize   int64
+	remaining int64
+}
+
+func (s *responseStream) Read(p []byte) (int, error) {
+	if s.maxSize <= 0 {
+		return s.body.Read(p)
+	}
+	if s.remaining < 0 {
+		return 0, s.sizeExceededError()
+	}
+	// read one byte past the limit to tell an exact fit from an oversized body
+	if int64(len(p)) > s.remaining+1 {
+		p = p[:s.remaining+1]
+	}
+	n, err := s.body.Read(p)
+	s.remaining -= int64(n)
+	if s.remaining < 0 {
+		return n + int(s.remaining), s.sizeExceededError()
+	}
+	return n, err
+}
+
+func (s *responseStream) sizeExceededError() error {
+	return fmt.Errorf("response body exceeds maximum allowed size of %d bytes", s.maxSize)
+}
+
+func (s *responseStream) Close() error {
+	return s.body.Close()
+}
+
+// completingStream invokes onClose once the stream is closed, the client attaches it to the response it resolves
+// a request with so discarded attempts(e.g. retried ones) do not complete the request.
+type completingStream struct {
+	io.ReadCloser
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func (s *completingStream) Close() (err error) {
+	s.closeOnce.Do(func() {
+		err = s.ReadCloser.Close()
+		s.onClose()
+	})
+	return
 }
 
 type awaitableResponse struct {
@@ -5,6 +5,8 @@ import (
 	"github.com/dlshle/gommon/retry"
 )
 
+// RetryInterceptor retries any request answered with one of retryStatusCodes or failing with an error, see
+// RetryPolicyInterceptor for Retry-After, idempotency checks and retry budgets.
 func RetryInterceptor(options *retry.RetryOptions, retryStatusCodes map[int]bool) Interceptor {
 	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
 		return retry.Retry1(func() (*Response, error) {
@@ -13,6 +15,8 @@ func RetryInterceptor(options *retry.RetryOptions, retryStatusCodes map[int]bool
 				return nil, err
 			}
 			if resp.Code == 0 || retryStatusCodes[resp.Code] {
+				// release the stream of a response that is thrown away
+				resp.Close()
 				return nil, errors.Errorf("retryable error for code %d", resp.Code)
 			}
 			return resp, nil
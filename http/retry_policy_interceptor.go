@@ -0,0 +1,196 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderRetryAfter     = "Retry-After"
+
+	DefaultRetryMaxAttempts     = 3
+	DefaultRetryBaseDelay       = 100 * time.Millisecond
+	DefaultRetryMaxDelay        = 10 * time.Second
+	DefaultRetryBudgetMaxTokens = 10
+)
+
+var defaultRetryStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// RetryBudget caps retries to a ratio of the requests, every request earns ratio tokens and every retry spends
+// one. Tokens are capped at maxTokens, which is also the number of retries available right away.
+type RetryBudget struct {
+	mutex     *sync.Mutex
+	ratio     float64
+	maxTokens float64
+	tokens    float64
+}
+
+func NewRetryBudget(ratio float64, maxTokens float64) *RetryBudget {
+	if maxTokens <= 0 {
+		maxTokens = DefaultRetryBudgetMaxTokens
+	}
+	return &RetryBudget{mutex: new(sync.Mutex), ratio: ratio, maxTokens: maxTokens, tokens: maxTokens}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first attempt.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the full-jitter backoff, a random delay in [0, min(MaxDelay, BaseDelay*2^n)).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryStatusCodes are retried, 429, 502, 503 and 504 by default.
+	RetryStatusCodes map[int]bool
+	// RetryOnError decides whether a failed attempt is retried, all errors but context ones by default.
+	RetryOnError func(err error) bool
+	// RetryNonIdempotent also retries requests that are neither idempotent nor carry an Idempotency-Key.
+	RetryNonIdempotent bool
+	// Budget limits retries across all requests sharing it, unlimited if nil.
+	Budget *RetryBudget
+}
+
+// RetryPolicyInterceptor retries failed attempts with full-jitter backoff. A Retry-After header on 429 and 503
+// responses replaces the backoff, the response is returned as is if it asks to wait longer than MaxDelay. Only
+// idempotent methods and requests with an Idempotency-Key are retried unless RetryNonIdempotent is set.
+func RetryPolicyInterceptor(policy *RetryPolicy) Interceptor {
+	p := RetryPolicy{}
+	if policy != nil {
+		p = *policy
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryMaxDelay
+	}
+	if p.RetryStatusCodes == nil {
+		p.RetryStatusCodes = defaultRetryStatusCodes
+	}
+	if p.RetryOnError == nil {
+		p.RetryOnError = func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		}
+	}
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		if p.Budget != nil {
+			p.Budget.deposit()
+		}
+		retryable := p.RetryNonIdempotent || isIdempotentRequest(request)
+		for attempt := 0; ; attempt++ {
+			resp, err := next(request)
+			if !retryable || attempt+1 >= p.MaxAttempts || !p.shouldRetry(resp, err) {
+				return resp, err
+			}
+			delay := p.backoff(attempt)
+			if retryAfter, ok := retryAfterDelay(resp); ok {
+				if retryAfter > p.MaxDelay {
+					return resp, err
+				}
+				delay = retryAfter
+			}
+			if p.Budget != nil && !p.Budget.withdraw() {
+				return resp, err
+			}
+			resp.Close()
+			if waitErr := waitRetryDelay(request.Context(), delay); waitErr != nil {
+				return nil, waitErr
+			}
+		}
+	}
+}
+
+func (p *RetryPolicy) shouldRetry(resp *Response, err error) bool {
+	if err != nil {
+		return p.RetryOnError(err)
+	}
+	return p.RetryStatusCodes[resp.Code]
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if attempt < 32 {
+		if exp := p.BaseDelay << attempt; exp > 0 && exp < ceiling {
+			ceiling = exp
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func isIdempotentRequest(request *Request) bool {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return request.Header.Get(HeaderIdempotencyKey) != ""
+}
+
+// retryAfterDelay parses the Retry-After header of 429 and 503 responses, in seconds or as an HTTP date.
+func retryAfterDelay(resp *Response) (time.Duration, bool) {
+	if resp == nil || (resp.Code != http.StatusTooManyRequests && resp.Code != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	value := resp.Header.Get(HeaderRetryAfter)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := time.Until(at); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+func waitRetryDelay(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyHonorsRetryAfter(t *testing.T) {
+	var hits int32
+	var firstAt, secondAt atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			firstAt.Store(time.Now().UnixNano())
+			w.Header().Set(HeaderRetryAfter, "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		secondAt.Store(time.Now().UnixNano())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(RetryPolicyInterceptor(&RetryPolicy{BaseDelay: time.Millisecond})).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected the retry to succeed, got %v, %v", resp, err)
+	}
+	if waited := time.Duration(secondAt.Load() - firstAt.Load()); waited < 900*time.Millisecond {
+		t.Errorf("expected the retry to wait for Retry-After, waited %s", waited)
+	}
+
+	// a Retry-After beyond MaxDelay returns the response right away
+	atomic.StoreInt32(&hits, 0)
+	c2 := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(RetryPolicyInterceptor(&RetryPolicy{MaxDelay: 100 * time.Millisecond})).Build()
+	defer c2.Stop()
+	req, _ = NewRequestBuilder().URL(ts.URL).Build()
+	if resp, err = c2.Request(req); err != nil || resp.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the 429 to be returned, got %v, %v", resp, err)
+	}
+}
+
+func TestRetryPolicyRetriesOnlyIdempotentRequests(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(RetryPolicyInterceptor(&RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})).Build()
+	defer c.Stop()
+
+	cases := []struct {
+		method   string
+		header   http.Header
+		expected int32
+	}{
+		{http.MethodPost, http.Header{}, 1},
+		{http.MethodPost, http.Header{HeaderIdempotencyKey: {"key-1"}}, 3},
+		{http.MethodPut, http.Header{}, 3},
+		{http.MethodGet, http.Header{}, 3},
+	}
+	for _, tc := range cases {
+		atomic.StoreInt32(&hits, 0)
+		req, _ := NewRequestBuilder().Method(tc.method).URL(ts.URL).Header(tc.header).StringBody("body").Build()
+		resp, err := c.Request(req)
+		if err != nil || resp.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected the last 503, got %v, %v", tc.method, resp, err)
+		}
+		if n := atomic.LoadInt32(&hits); n != tc.expected {
+			t.Errorf("%s %v: expected %d attempts, got %d", tc.method, tc.header, tc.expected, n)
+		}
+	}
+}
+
+func TestRetryPolicyDefaultsNilPolicy(t *testing.T) {
+	attempts := 0
+	req, _ := NewRequestBuilder().Method(http.MethodPost).URL("http://localhost").Build()
+	resp, err := RetryPolicyInterceptor(nil)(req, func(r *Request) (*Response, error) {
+		attempts++
+		return &Response{Code: http.StatusServiceUnavailable}, nil
+	})
+	if err != nil || resp.Code != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("expected a single attempt of the non idempotent request, got %d: %v, %v", attempts, resp, err)
+	}
+}
+
+func TestRetryBudgetLimitsRetries(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	budget := NewRetryBudget(0.1, 2)
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(RetryPolicyInterceptor(&RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, Budget: budget})).Build()
+	defer c.Stop()
+
+	for i := 0; i < 10; i++ {
+		req, _ := NewRequestBuilder().URL(ts.URL).Build()
+		if _, err := c.Request(req); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+	// the 2 initial tokens go to the first request, the other 9 only earn 0.9 tokens
+	if n := atomic.LoadInt32(&hits); n != 12 {
+		t.Errorf("expected 2 retries within the budget, got %d attempts", n)
+	}
+}
+
+func TestRetryPolicyBackoffIsJittered(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt := 0; attempt < 40; attempt++ {
+		ceiling := 50 * time.Millisecond
+		if attempt < 3 {
+			ceiling = 10 * time.Millisecond << attempt
+		}
+		if delay := p.backoff(attempt); delay < 0 || delay >= ceiling {
+			t.Errorf("attempt %d: delay %s outside [0, %s)", attempt, delay, ceiling)
+		}
+	}
+	resp := &Response{Code: http.StatusServiceUnavailable, Header: http.Header{HeaderRetryAfter: {strconv.Itoa(2)}}}
+	if delay, ok := retryAfterDelay(resp); !ok || delay != 2*time.Second {
+		t.Errorf("expected a 2s Retry-After, got %s", delay)
+	}
+	resp.Header.Set(HeaderRetryAfter, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if delay, ok := retryAfterDelay(resp); !ok || delay < 59*time.Minute {
+		t.Errorf("expected an HTTP date Retry-After, got %s", delay)
+	}
+}
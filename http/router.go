@@ -0,0 +1,290 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dlshle/gommon/uri_trie"
+)
+
+// Middleware is the server side counterpart of Interceptor, it calls next to pass the request on.
+type Middleware = func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request))
+
+func serveMiddlewares(middlewares []Middleware, w http.ResponseWriter, request *Request, handler func(http.ResponseWriter, *Request)) {
+	if len(middlewares) == 0 {
+		handler(w, request)
+		return
+	}
+	middlewares[0](w, request, func(currW http.ResponseWriter, currReq *Request) {
+		serveMiddlewares(middlewares[1:], currW, currReq, handler)
+	})
+}
+
+// RouteGroup registers routes under a common prefix and middlewares.
+type RouteGroup interface {
+	Handle(method, pattern string, handler http.Handler) error
+	HandleFunc(method, pattern string, handler http.HandlerFunc) error
+	GET(pattern string, handler http.HandlerFunc) error
+	POST(pattern string, handler http.HandlerFunc) error
+	PUT(pattern string, handler http.HandlerFunc) error
+	PATCH(pattern string, handler http.HandlerFunc) error
+	DELETE(pattern string, handler http.HandlerFunc) error
+	// Use adds middlewares to the routes of the group.
+	Use(middlewares ...Middleware)
+	Group(prefix string, middlewares ...Middleware) RouteGroup
+}
+
+// Router is an http.Handler dispatching on method and uri_trie patterns(e.g. /users/:id or /static/*path).
+// Middlewares added with Use run for every request, including the ones answered with 404 or 405.
+type Router interface {
+	http.Handler
+	RouteGroup
+	NotFound(handler http.Handler)
+	MethodNotAllowed(handler http.Handler)
+}
+
+type routeMatchKey struct{}
+
+// RouteMatch returns the match of the route serving the request, nil outside of a Router.
+func RouteMatch(ctx context.Context) *uri_trie.MatchContext {
+	match, _ := ctx.Value(routeMatchKey{}).(*uri_trie.MatchContext)
+	return match
+}
+
+// PathParam returns the value of a :param or *wildcard of the matched route.
+func PathParam(request *Request, name string) string {
+	if match := RouteMatch(request.Context()); match != nil {
+		return match.PathParams[name]
+	}
+	return ""
+}
+
+// RoutePattern returns the pattern of the matched route.
+func RoutePattern(request *Request) string {
+	if match := RouteMatch(request.Context()); match != nil {
+		return match.UriPattern
+	}
+	return ""
+}
+
+type routeHandler struct {
+	group   *routeGroup
+	handler http.Handler
+}
+
+type route struct {
+	handlers map[string]*routeHandler
+}
+
+func (r *route) allowed() string {
+	methods := make([]string, 0, len(r.handlers))
+	for method := range r.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := r.handlers[http.MethodGet]; ok {
+		if _, ok = r.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+type router struct {
+	*routeGroup
+	tree             *uri_trie.TrieTree
+	rwMutex          *sync.RWMutex
+	routes           map[string]*route
+	middlewares      []Middleware
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+func NewRouter() Router {
+	r := &router{
+		tree:             uri_trie.NewTrieTree(),
+		rwMutex:          new(sync.RWMutex),
+		routes:           make(map[string]*route),
+		notFound:         http.NotFoundHandler(),
+		methodNotAllowed: http.HandlerFunc(defaultMethodNotAllowed),
+	}
+	r.routeGroup = &routeGroup{router: r}
+	return r
+}
+
+func defaultMethodNotAllowed(w http.ResponseWriter, _ *Request) {
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func (r *router) Use(middlewares ...Middleware) {
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+func (r *router) NotFound(handler http.Handler) {
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+	r.notFound = handler
+}
+
+func (r *router) MethodNotAllowed(handler http.Handler) {
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+	r.methodNotAllowed = handler
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, request *Request) {
+	r.rwMutex.RLock()
+	middlewares := r.middlewares
+	r.rwMutex.RUnlock()
+	serveMiddlewares(middlewares, w, request, r.dispatch)
+}
+
+func (r *router) dispatch(w http.ResponseWriter, request *Request) {
+	path := request.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	match, err := r.tree.Match(path)
+	r.rwMutex.RLock()
+	notFound, methodNotAllowed := r.notFound, r.methodNotAllowed
+	var (
+		matched *route
+		handler *routeHandler
+	)
+	if err == nil && match != nil {
+		matched = match.Value.(*route)
+		handler = matched.handlers[request.Method]
+		if handler == nil && request.Method == http.MethodHead {
+			handler = matched.handlers[http.MethodGet]
+		}
+	}
+	var allow string
+	if matched != nil && handler == nil {
+		allow = matched.allowed()
+	}
+	r.rwMutex.RUnlock()
+
+	switch {
+	case matched == nil:
+		notFound.ServeHTTP(w, request)
+	case handler == nil:
+		w.Header().Set("Allow", allow)
+		methodNotAllowed.ServeHTTP(w, request)
+	default:
+		request = request.WithContext(context.WithValue(request.Context(), routeMatchKey{}, match))
+		serveMiddlewares(handler.group.chain(), w, request, handler.handler.ServeHTTP)
+	}
+}
+
+func (r *router) add(method, pattern string, group *routeGroup, handler http.Handler) error {
+	if handler == nil {
+		return fmt.Errorf("nil handler for %s %s", method, pattern)
+	}
+	method = strings.ToUpper(method)
+	r.rwMutex.Lock()
+	defer r.rwMutex.Unlock()
+	existing := r.routes[pattern]
+	if existing == nil {
+		existing = &route{handlers: make(map[string]*routeHandler)}
+		if err := r.tree.Add(pattern, existing, false); err != nil {
+			return err
+		}
+		r.routes[pattern] = existing
+	} else if existing.handlers[method] != nil {
+		return fmt.Errorf("route %s %s has already been registered", method, pattern)
+	}
+	existing.handlers[method] = &routeHandler{group: group, handler: handler}
+	return nil
+}
+
+type routeGroup struct {
+	router      *router
+	parent      *routeGroup
+	prefix      string
+	middlewares []Middleware
+}
+
+// chain returns the middlewares of the group and its parents, outermost first.
+func (g *routeGroup) chain() []Middleware {
+	var chain []Middleware
+	if g.parent != nil {
+		chain = g.parent.chain()
+	}
+	g.router.rwMutex.RLock()
+	defer g.router.rwMutex.RUnlock()
+	return append(chain, g.middlewares...)
+}
+
+func (g *routeGroup) Use(middlewares ...Middleware) {
+	g.router.rwMutex.Lock()
+	defer g.router.rwMutex.Unlock()
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+func (g *routeGroup) Group(prefix string, middlewares ...Middleware) RouteGroup {
+	return &routeGroup{
+		router:      g.router,
+		parent:      g,
+		prefix:      joinRoutePath(g.prefix, prefix),
+		middlewares: append([]Middleware(nil), middlewares...),
+	}
+}
+
+func (g *routeGroup) Handle(method, pattern string, handler http.Handler) error {
+	return g.router.add(method, normalizeRoutePattern(joinRoutePath(g.prefix, pattern)), g, handler)
+}
+
+func (g *routeGroup) HandleFunc(method, pattern string, handler http.HandlerFunc) error {
+	if handler == nil {
+		return fmt.Errorf("nil handler for %s %s", method, pattern)
+	}
+	return g.Handle(method, pattern, handler)
+}
+
+func (g *routeGroup) GET(pattern string, handler http.HandlerFunc) error {
+	return g.HandleFunc(http.MethodGet, pattern, handler)
+}
+
+func (g *routeGroup) POST(pattern string, handler http.HandlerFunc) error {
+	return g.HandleFunc(http.MethodPost, pattern, handler)
+}
+
+func (g *routeGroup) PUT(pattern string, handler http.HandlerFunc) error {
+	return g.HandleFunc(http.MethodPut, pattern, handler)
+}
+
+func (g *routeGroup) PATCH(pattern string, handler http.HandlerFunc) error {
+	return g.HandleFunc(http.MethodPatch, pattern, handler)
+}
+
+func (g *routeGroup) DELETE(pattern string, handler http.HandlerFunc) error {
+	return g.HandleFunc(http.MethodDelete, pattern, handler)
+}
+
+func joinRoutePath(prefix, path string) string {
+	if path == "" || path == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// normalizeRoutePattern mirrors the trailing slash handling of uri_trie.TrieTree so patterns map to one route.
+func normalizeRoutePattern(pattern string) string {
+	pattern = strings.TrimSpace(pattern)
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return pattern
+}
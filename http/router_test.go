@@ -0,0 +1,116 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveTestRequest(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	return serveTestRequestWith(handler, httptest.NewRequest(method, target, nil))
+}
+
+func serveTestRequestWith(handler http.Handler, request *Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRouterDispatchesOnMethodAndPattern(t *testing.T) {
+	r := NewRouter()
+	mustRoute := func(err error) {
+		if err != nil {
+			t.Fatalf("failed to register route: %v", err)
+		}
+	}
+	mustRoute(r.GET("/", func(w http.ResponseWriter, req *Request) {
+		io.WriteString(w, "root")
+	}))
+	mustRoute(r.GET("/users/:id", func(w http.ResponseWriter, req *Request) {
+		io.WriteString(w, "get "+PathParam(req, "id")+" "+RoutePattern(req))
+	}))
+	mustRoute(r.DELETE("/users/:id", func(w http.ResponseWriter, req *Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mustRoute(r.GET("/static/*path", func(w http.ResponseWriter, req *Request) {
+		io.WriteString(w, PathParam(req, "path"))
+	}))
+	if err := r.GET("/users/:id/", func(http.ResponseWriter, *Request) {}); err == nil {
+		t.Errorf("expected duplicated routes to be rejected")
+	}
+
+	cases := []struct {
+		method, target string
+		code           int
+		body           string
+	}{
+		{http.MethodGet, "/", http.StatusOK, "root"},
+		{http.MethodGet, "/users/42?verbose=true", http.StatusOK, "get 42 /users/:id"},
+		{http.MethodGet, "/users/42/", http.StatusOK, "get 42 /users/:id"},
+		{http.MethodHead, "/users/42", http.StatusOK, ""},
+		{http.MethodDelete, "/users/42", http.StatusNoContent, ""},
+		{http.MethodGet, "/static/css/site.css", http.StatusOK, "css/site.css"},
+		{http.MethodGet, "/missing", http.StatusNotFound, "404 page not found\n"},
+		{http.MethodGet, "/users/42/posts", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, c := range cases {
+		resp := serveTestRequest(r, c.method, c.target)
+		if resp.Code != c.code || (c.method != http.MethodHead && resp.Body.String() != c.body) {
+			t.Errorf("%s %s: expected %d %q, got %d %q", c.method, c.target, c.code, c.body, resp.Code, resp.Body.String())
+		}
+	}
+
+	resp := serveTestRequest(r, http.MethodPut, "/users/42")
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "DELETE, GET, HEAD" {
+		t.Errorf("expected 405 with Allow header, got %d %q", resp.Code, resp.Header().Get("Allow"))
+	}
+}
+
+func TestRouterGroupsAndMiddlewares(t *testing.T) {
+	var trace []string
+	record := func(name string) Middleware {
+		return func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request)) {
+			trace = append(trace, name)
+			next(w, request)
+		}
+	}
+	denyAll := func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request)) {
+		w.WriteHeader(http.StatusForbidden)
+	}
+
+	r := NewRouter()
+	r.Use(record("global"))
+	api := r.Group("/api", record("api"))
+	v1 := api.Group("v1/")
+	v1.Use(record("v1"))
+	v1.GET("/items/:id", func(w http.ResponseWriter, req *Request) {
+		trace = append(trace, "handler "+PathParam(req, "id"))
+	})
+	admin := api.Group("/admin", denyAll)
+	admin.GET("/stats", func(w http.ResponseWriter, req *Request) {
+		t.Errorf("expected the admin middleware to stop the request")
+	})
+	r.NotFound(http.HandlerFunc(func(w http.ResponseWriter, req *Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	if resp := serveTestRequest(r, http.MethodGet, "/api/v1/items/7"); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+	if got := strings.Join(trace, ","); got != "global,api,v1,handler 7" {
+		t.Errorf("unexpected middleware order %s", got)
+	}
+
+	trace = nil
+	if resp := serveTestRequest(r, http.MethodGet, "/api/admin/stats"); resp.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", resp.Code)
+	}
+	if resp := serveTestRequest(r, http.MethodGet, "/nowhere"); resp.Code != http.StatusTeapot {
+		t.Errorf("expected the custom not found handler, got %d", resp.Code)
+	}
+	if got := strings.Join(trace, ","); got != "global,api,global" {
+		t.Errorf("expected global middlewares to run for unmatched requests, got %s", got)
+	}
+}
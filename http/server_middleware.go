@@ -0,0 +1,177 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dlshle/gommon/errors"
+	"github.com/dlshle/gommon/logging"
+	"github.com/dlshle/gommon/utils"
+)
+
+const (
+	HeaderXRequestID = "X-Request-Id"
+
+	LoggingCtxRequestID = "request_id"
+
+	requestIDSize = 16
+)
+
+// WithMiddlewares wraps handler with middlewares, it is meant for per-route middlewares such as TimeoutMiddleware.
+func WithMiddlewares(handler http.Handler, middlewares ...Middleware) http.Handler {
+	middlewares = append([]Middleware(nil), middlewares...)
+	return http.HandlerFunc(func(w http.ResponseWriter, request *Request) {
+		serveMiddlewares(middlewares, w, request, handler.ServeHTTP)
+	})
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the id assigned by RequestIDMiddleware.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware keeps the X-Request-Id of incoming requests or generates one, the id is echoed in the
+// response and added to the logging context of the request.
+func RequestIDMiddleware() Middleware {
+	return func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request)) {
+		id := request.Header.Get(HeaderXRequestID)
+		if id == "" || len(id) > 128 {
+			id = utils.RandomStringWithSize(requestIDSize)
+		}
+		w.Header().Set(HeaderXRequestID, id)
+		ctx := context.WithValue(request.Context(), requestIDKey{}, id)
+		next(w, request.WithContext(logging.WrapCtx(ctx, LoggingCtxRequestID, id)))
+	}
+}
+
+// AccessLogMiddleware logs a line per request with the method, path, status, size and duration in the logging
+// context. Register it after RequestIDMiddleware to have request ids in the access log.
+func AccessLogMiddleware(logger logging.Logger) Middleware {
+	if logger == nil {
+		logger = logging.GlobalLogger
+	}
+	return func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request)) {
+		start := time.Now()
+		recorder := newStatusRecorder(w)
+		defer func() {
+			ctx := request.Context()
+			for key, value := range map[string]string{
+				"method":      request.Method,
+				"path":        request.URL.Path,
+				"status":      strconv.Itoa(recorder.status()),
+				"bytes":       strconv.FormatInt(recorder.written, 10),
+				"duration_ms": strconv.FormatInt(time.Since(start).Milliseconds(), 10),
+				"remote_addr": request.RemoteAddr,
+			} {
+				ctx = logging.WrapCtx(ctx, key, value)
+			}
+			logger.Infof(ctx, "%s %s %d", request.Method, request.URL.RequestURI(), recorder.status())
+		}()
+		next(recorder, request)
+	}
+}
+
+// RecoveryMiddleware turns panics into 500 responses and logs them with their stacktrace. Register it after
+// AccessLogMiddleware so recovered requests are logged with their 500 status.
+func RecoveryMiddleware(logger logging.Logger) Middleware {
+	if logger == nil {
+		logger = logging.GlobalLogger
+	}
+	return func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request)) {
+		recorder := newStatusRecorder(w)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// the server aborts the response on purpose
+				panic(recovered)
+			}
+			err := errors.Errorf("panic serving %s %s: %v", request.Method, request.URL.Path, recovered)
+			logger.TrackableError(request.Context(), err, err.CausingError().Error())
+			if !recorder.wroteHeader {
+				http.Error(recorder, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next(recorder, request)
+	}
+}
+
+// TimeoutMiddleware cancels the request context after timeout and answers 503 if the handler has not responded
+// by then, see http.TimeoutHandler. Wrap single routes with WithMiddlewares to give them their own timeouts.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(w http.ResponseWriter, request *Request, next func(http.ResponseWriter, *Request)) {
+		http.TimeoutHandler(http.HandlerFunc(next), timeout, fmt.Sprintf("handler timed out after %s", timeout)).ServeHTTP(w, request)
+	}
+}
+
+// statusRecorder records the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+	written     int64
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	if recorder, ok := w.(*statusRecorder); ok {
+		return recorder
+	}
+	return &statusRecorder{ResponseWriter: w}
+}
+
+func (r *statusRecorder) status() int {
+	if !r.wroteHeader {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code = code
+		r.wroteHeader = code >= 200
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.code = http.StatusOK
+		r.wroteHeader = true
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.written += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		if !r.wroteHeader {
+			r.code = http.StatusOK
+			r.wroteHeader = true
+		}
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dlshle/gommon/logging"
+)
+
+type capturingLogWriter struct {
+	mutex    sync.Mutex
+	entities []*logging.LogEntity
+}
+
+func (w *capturingLogWriter) Write(entity *logging.LogEntity) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.entities = append(w.entities, entity)
+	return nil
+}
+
+func (w *capturingLogWriter) all() []*logging.LogEntity {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return append([]*logging.LogEntity(nil), w.entities...)
+}
+
+func newCapturingLogger() (logging.Logger, *capturingLogWriter) {
+	writer := &capturingLogWriter{}
+	logger := logging.NewDefaultLogger(&bytes.Buffer{}, "", logging.LogAllWaterMark)
+	logger.SetWriter(writer)
+	return logger, writer
+}
+
+func TestServerMiddlewaresLogRequests(t *testing.T) {
+	logger, writer := newCapturingLogger()
+	r := NewRouter()
+	r.Use(RequestIDMiddleware(), AccessLogMiddleware(logger), RecoveryMiddleware(logger))
+	r.GET("/items/:id", func(w http.ResponseWriter, req *Request) {
+		io.WriteString(w, RequestID(req.Context()))
+	})
+	r.GET("/panic", func(w http.ResponseWriter, req *Request) {
+		panic("boom")
+	})
+
+	resp := serveTestRequest(r, http.MethodGet, "/items/1")
+	id := resp.Header().Get(HeaderXRequestID)
+	if resp.Code != http.StatusOK || id == "" || resp.Body.String() != id {
+		t.Fatalf("expected the request id in the response, got %d %q %q", resp.Code, id, resp.Body.String())
+	}
+	entities := writer.all()
+	if len(entities) != 1 {
+		t.Fatalf("expected 1 access log, got %d", len(entities))
+	}
+	access := entities[0].Context
+	if access[LoggingCtxRequestID] != id || access["method"] != http.MethodGet || access["path"] != "/items/1" ||
+		access["status"] != "200" || access["bytes"] != "16" {
+		t.Errorf("unexpected access log context %v", access)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(HeaderXRequestID, "incoming-id")
+	recorder := serveTestRequestWith(r, req)
+	if recorder.Code != http.StatusInternalServerError || recorder.Header().Get(HeaderXRequestID) != "incoming-id" {
+		t.Fatalf("expected a 500 carrying the incoming request id, got %d", recorder.Code)
+	}
+	entities = writer.all()
+	if len(entities) != 3 {
+		t.Fatalf("expected the panic and the access log, got %d entries", len(entities))
+	}
+	recovered, access := entities[1], entities[2].Context
+	if recovered.Level != logging.ERROR || !strings.Contains(recovered.Message, "boom") ||
+		recovered.Context["stacktrace"] == "" || recovered.Context[LoggingCtxRequestID] != "incoming-id" {
+		t.Errorf("unexpected panic log %+v", recovered)
+	}
+	if access["status"] != "500" {
+		t.Errorf("expected the access log to record the 500, got %v", access)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	r := NewRouter()
+	r.GET("/slow", WithMiddlewares(http.HandlerFunc(func(w http.ResponseWriter, req *Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+			io.WriteString(w, "too late")
+		}
+	}), TimeoutMiddleware(20*time.Millisecond)).ServeHTTP)
+	r.GET("/fast", func(w http.ResponseWriter, req *Request) {
+		io.WriteString(w, "fast")
+	})
+
+	if resp := serveTestRequest(r, http.MethodGet, "/slow"); resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for the slow route, got %d %q", resp.Code, resp.Body.String())
+	}
+	if resp := serveTestRequest(r, http.MethodGet, "/fast"); resp.Code != http.StatusOK || resp.Body.String() != "fast" {
+		t.Errorf("expected other routes to be unaffected, got %d", resp.Code)
+	}
+}
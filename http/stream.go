@@ -0,0 +1,97 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is a single server-sent event.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry is the reconnection time requested by the server, 0 if not set.
+	Retry time.Duration
+}
+
+// SSEDecoder reads server-sent events(text/event-stream) from a streamed response body.
+type SSEDecoder struct {
+	reader *bufio.Reader
+	lastID string
+}
+
+func NewSSEDecoder(r io.Reader) *SSEDecoder {
+	return &SSEDecoder{reader: bufio.NewReader(r)}
+}
+
+// Next returns the next event, io.EOF when the stream ends.
+func (d *SSEDecoder) Next() (*SSEEvent, error) {
+	var (
+		event   SSEEvent
+		data    strings.Builder
+		hasData bool
+	)
+	for {
+		line, err := d.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if !hasData {
+				// events without data are not dispatched
+				event = SSEEvent{}
+				continue
+			}
+			event.ID = d.lastID
+			event.Data = data.String()
+			return &event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			event.Event = value
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastID = value
+			}
+		case "retry":
+			if ms, parseErr := strconv.Atoi(value); parseErr == nil && ms >= 0 {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if err == io.EOF {
+			// the stream ended in the middle of an event, which is discarded
+			return nil, io.EOF
+		}
+	}
+}
+
+// NDJSONDecoder reads newline-delimited JSON values from a streamed response body.
+type NDJSONDecoder[T any] struct {
+	decoder *json.Decoder
+}
+
+func NewNDJSONDecoder[T any](r io.Reader) *NDJSONDecoder[T] {
+	return &NDJSONDecoder[T]{decoder: json.NewDecoder(r)}
+}
+
+// Next returns the next value, io.EOF when the stream ends.
+func (d *NDJSONDecoder[T]) Next() (holder T, err error) {
+	err = d.decoder.Decode(&holder)
+	return
+}
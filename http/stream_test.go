@@ -0,0 +1,178 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dlshle/gommon/retry"
+)
+
+func TestStreamResponseReleasesWorker(t *testing.T) {
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast" {
+			_, _ = w.Write([]byte("fast"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("first-"))
+		w.(http.Flusher).Flush()
+		<-unblock
+		_, _ = w.Write([]byte("second"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL + "/stream").Stream(true).Timeout(5 * time.Second).Build()
+	resp, err := c.Request(req)
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Close()
+	if resp.Stream == nil || resp.Body != nil {
+		t.Fatalf("expected streamed response, got %+v", resp)
+	}
+
+	// the only worker is free again while the stream is still open
+	fastReq, _ := NewRequestBuilder().URL(ts.URL + "/fast").Build()
+	fastResp, err := c.Request(fastReq)
+	if err != nil || string(fastResp.Body) != "fast" {
+		t.Fatalf("expected worker to be released, got %v, %v", fastResp, err)
+	}
+
+	close(unblock)
+	body, err := io.ReadAll(resp.Stream)
+	if err != nil || string(body) != "first-second" {
+		t.Fatalf("unexpected stream body %q, %v", body, err)
+	}
+}
+
+func TestStreamResponseSurvivesRetries(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("streamed"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(RetryInterceptor(&retry.RetryOptions{MaxRetries: 3}, map[int]bool{http.StatusServiceUnavailable: true})).
+		Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Stream(true).Timeout(5 * time.Second).Build()
+	resp, err := c.Request(req)
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v after %d hits", err, atomic.LoadInt32(&hits))
+	}
+	body, err := io.ReadAll(resp.Stream)
+	if err != nil || string(body) != "streamed" {
+		t.Fatalf("unexpected stream body %q, %v", body, err)
+	}
+	resp.Close()
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestStreamResponseMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).MaxResponseBodySize(5).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Stream(true).Build()
+	resp, err := c.Request(req)
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Close()
+	body, err := io.ReadAll(resp.Stream)
+	if err == nil {
+		t.Fatalf("expected size limit error, read %q", body)
+	}
+	if string(body) != "hello" {
+		t.Errorf("expected data up to the limit, got %q", body)
+	}
+}
+
+func TestSSEDecoder(t *testing.T) {
+	stream := strings.Join([]string{
+		": comment",
+		"retry: 1500",
+		"event: update",
+		"id: 1",
+		"data: line1",
+		"data: line2",
+		"",
+		"event: ignored-without-data",
+		"",
+		"data:plain\r",
+		"\r",
+		"data: dangling",
+	}, "\n")
+	decoder := NewSSEDecoder(strings.NewReader(stream))
+
+	first, err := decoder.Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Event != "update" || first.ID != "1" || first.Data != "line1\nline2" || first.Retry != 1500*time.Millisecond {
+		t.Errorf("unexpected first event %+v", first)
+	}
+	second, err := decoder.Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Event != "" || second.Data != "plain" || second.ID != "1" {
+		t.Errorf("unexpected second event %+v", second)
+	}
+	if _, err = decoder.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestNDJSONDecoderOverStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprintf(w, "{\"name\":\"item\",\"count\":%d}\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	c := NewBuilder().TimeoutSec(5).Build()
+	defer c.Stop()
+
+	req, _ := NewRequestBuilder().URL(ts.URL).Stream(true).Build()
+	resp, err := c.Request(req)
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Close()
+
+	decoder := NewNDJSONDecoder[jsonTestPayload](resp.Stream)
+	for i := 0; i < 3; i++ {
+		item, err := decoder.Next()
+		if err != nil || item.Count != i {
+			t.Fatalf("expected item %d, got %+v, %v", i, item, err)
+		}
+	}
+	if _, err = decoder.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConnectionTimings breaks down where the time of a request attempt went, phases that did not happen(e.g. DNS
+// and connect on reused connections) are zero.
+type ConnectionTimings struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// TimeToFirstByte is the time from the start of the attempt to the first response byte.
+	TimeToFirstByte time.Duration
+	// ServerProcessing is the time from the request being written to the first response byte.
+	ServerProcessing time.Duration
+	// Total ends once the response body is read, or once the headers arrive for streamed responses.
+	Total      time.Duration
+	ConnReused bool
+}
+
+func (t *ConnectionTimings) String() string {
+	return fmt.Sprintf("dns=%s connect=%s tls=%s ttfb=%s server=%s total=%s reused=%t",
+		t.DNS, t.Connect, t.TLSHandshake, t.TimeToFirstByte, t.ServerProcessing, t.Total, t.ConnReused)
+}
+
+// LogFields returns the timings in milliseconds, meant to be added to a logging context with logging.WrapCtx.
+func (t *ConnectionTimings) LogFields() map[string]string {
+	millis := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	}
+	return map[string]string{
+		"dns_ms":      millis(t.DNS),
+		"connect_ms":  millis(t.Connect),
+		"tls_ms":      millis(t.TLSHandshake),
+		"ttfb_ms":     millis(t.TimeToFirstByte),
+		"server_ms":   millis(t.ServerProcessing),
+		"total_ms":    millis(t.Total),
+		"conn_reused": strconv.FormatBool(t.ConnReused),
+	}
+}
+
+// connectionTimer collects the httptrace events of one attempt, dialing may run in parallel(e.g. for IPv4 and
+// IPv6) so events are guarded and only the first start and last done of a phase count.
+type connectionTimer struct {
+	mutex        *sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newConnectionTimer() *connectionTimer {
+	return &connectionTimer{mutex: new(sync.Mutex), start: time.Now()}
+}
+
+func (t *connectionTimer) record(at *time.Time, first bool) {
+	now := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !first || at.IsZero() {
+		*at = now
+	}
+}
+
+func (t *connectionTimer) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.record(&t.dnsStart, true) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.record(&t.dnsDone, false) },
+		ConnectStart: func(string, string) {
+			t.record(&t.connectStart, true)
+		},
+		ConnectDone: func(string, string, error) {
+			t.record(&t.connectDone, false)
+		},
+		TLSHandshakeStart: func() { t.record(&t.tlsStart, true) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.record(&t.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.reused = info.Reused
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.record(&t.wroteRequest, false) },
+		GotFirstResponseByte: func() { t.record(&t.firstByte, true) },
+	})
+}
+
+func (t *connectionTimer) timings() *ConnectionTimings {
+	end := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	between := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+	return &ConnectionTimings{
+		DNS:              between(t.dnsStart, t.dnsDone),
+		Connect:          between(t.connectStart, t.connectDone),
+		TLSHandshake:     between(t.tlsStart, t.tlsDone),
+		TimeToFirstByte:  between(t.start, t.firstByte),
+		ServerProcessing: between(t.wroteRequest, t.firstByte),
+		Total:            end.Sub(t.start),
+		ConnReused:       t.reused,
+	}
+}
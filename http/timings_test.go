@@ -0,0 +1,68 @@
+package http
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientRecordsConnectionTimings(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	builder := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).ConnectionTimings(true)
+	builder.(*httpClientBuilder).transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	var seen *ConnectionTimings
+	c := builder.AddInterceptor(func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		resp, err := next(request)
+		if resp != nil {
+			seen = resp.Timings
+		}
+		return resp, err
+	}).Build()
+	defer c.Stop()
+
+	target := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	req, _ := NewRequestBuilder().URL(target).Build()
+	resp, err := c.Request(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timings := resp.Timings
+	if timings == nil || seen != timings {
+		t.Fatalf("expected the timings on the response seen by interceptors, got %v", timings)
+	}
+	if timings.ConnReused || timings.Connect <= 0 || timings.TLSHandshake <= 0 {
+		t.Errorf("expected a new TLS connection, got %s", timings)
+	}
+	if timings.ServerProcessing < 50*time.Millisecond || timings.TimeToFirstByte < timings.ServerProcessing ||
+		timings.Total < timings.TimeToFirstByte {
+		t.Errorf("unexpected server timings %s", timings)
+	}
+	if fields := timings.LogFields(); fields["conn_reused"] != "false" || fields["total_ms"] == "" {
+		t.Errorf("unexpected log fields %v", fields)
+	}
+
+	req, _ = NewRequestBuilder().URL(target).Build()
+	if resp, err = c.Request(req); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Timings.ConnReused || resp.Timings.DNS != 0 || resp.Timings.Connect != 0 || resp.Timings.TLSHandshake != 0 {
+		t.Errorf("expected the connection to be reused, got %s", resp.Timings)
+	}
+
+	plainBuilder := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5)
+	plainBuilder.(*httpClientBuilder).transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	plain := plainBuilder.Build()
+	defer plain.Stop()
+	req, _ = NewRequestBuilder().URL(ts.URL).Build()
+	if resp, _ = plain.Request(req); resp == nil || resp.Timings != nil {
+		t.Errorf("expected no timings unless enabled")
+	}
+}
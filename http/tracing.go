@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+
+	LoggingCtxTraceID = "trace_id"
+	LoggingCtxSpanID  = "span_id"
+
+	traceparentVersion = "00"
+	traceFlagSampled   = 0x01
+)
+
+// SpanContext identifies a span as described by the W3C trace context specification.
+type SpanContext struct {
+	TraceID    string // 32 lowercase hex characters
+	SpanID     string // 16 lowercase hex characters
+	Flags      byte
+	TraceState string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return isValidTraceHex(sc.TraceID, 32) && isValidTraceHex(sc.SpanID, 16)
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&traceFlagSampled != 0
+}
+
+// Traceparent renders the span context as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header value.
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent flags %q", parts[3])
+	}
+	sc := SpanContext{TraceID: parts[1], SpanID: parts[2], Flags: flags[0]}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent ids %q", traceparent)
+	}
+	return sc, nil
+}
+
+func isValidTraceHex(id string, size int) bool {
+	if len(id) != size || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomTraceHex(size int) string {
+	buf := make([]byte, size/2)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			panic(fmt.Sprintf("unable to generate trace id: %v", err))
+		}
+		if id := hex.EncodeToString(buf); strings.Trim(id, "0") != "" {
+			return id
+		}
+	}
+}
+
+func NewTraceID() string {
+	return randomTraceHex(32)
+}
+
+func NewSpanID() string {
+	return randomTraceHex(16)
+}
+
+type spanContextKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Span is a finished or in-flight unit of work.
+type Span struct {
+	Name         string
+	Context      SpanContext
+	ParentSpanID string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]string
+	Err          error
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// SpanExporter receives every span once it has ended.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemorySpanExporter keeps exported spans in memory, it is meant for tests.
+type InMemorySpanExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+func NewInMemorySpanExporter() *InMemorySpanExporter {
+	return &InMemorySpanExporter{}
+}
+
+func (e *InMemorySpanExporter) ExportSpan(span *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func (e *InMemorySpanExporter) Spans() []*Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+func (e *InMemorySpanExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = nil
+}
+
+// attemptCounterKey carries the counters the client increments for every attempt sent over the wire, which lets
+// interceptors wrapping RetryInterceptor observe the number of retries. Nested interceptors each get their own
+// counter and every counter in the context is incremented.
+type attemptCounterKey struct{}
+
+func withAttemptCounter(ctx context.Context) (context.Context, *int32) {
+	counter := new(int32)
+	parents, _ := ctx.Value(attemptCounterKey{}).([]*int32)
+	counters := append(append(make([]*int32, 0, len(parents)+1), parents...), counter)
+	return context.WithValue(ctx, attemptCounterKey{}, counters), counter
+}
+
+func countAttempt(ctx context.Context) {
+	counters, _ := ctx.Value(attemptCounterKey{}).([]*int32)
+	for _, counter := range counters {
+		atomic.AddInt32(counter, 1)
+	}
+}
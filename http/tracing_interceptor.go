@@ -0,0 +1,106 @@
+package http
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dlshle/gommon/logging"
+)
+
+const (
+	SpanAttrMethod     = "http.method"
+	SpanAttrURL        = "http.url"
+	SpanAttrStatusCode = "http.status_code"
+	SpanAttrRetries    = "http.retries"
+)
+
+type TracingOptions struct {
+	Exporter SpanExporter
+	// SpanName names the span of a request, "HTTP <method>" by default.
+	SpanName func(request *Request) string
+}
+
+// TracingInterceptor records a span per request and propagates it with the W3C traceparent and tracestate
+// headers. The parent span is taken from the request context(see ContextWithSpanContext) or from a traceparent
+// header already set on the request, otherwise a new trace is started. Trace and span ids are added to the
+// logging context of the request. Register it before RetryInterceptor so one span covers all attempts and
+// reports the number of retries.
+func TracingInterceptor(opts *TracingOptions) Interceptor {
+	options := TracingOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	if options.SpanName == nil {
+		options.SpanName = func(request *Request) string {
+			return "HTTP " + request.Method
+		}
+	}
+	return func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		span := &Span{
+			Name:      options.SpanName(request),
+			StartTime: time.Now(),
+			Attributes: map[string]string{
+				SpanAttrMethod: request.Method,
+				SpanAttrURL:    request.URL.String(),
+			},
+		}
+		parent, ok := SpanContextFromContext(request.Context())
+		if !ok {
+			parent, ok = parentFromHeader(request)
+		}
+		if ok {
+			span.Context = SpanContext{TraceID: parent.TraceID, SpanID: NewSpanID(), Flags: parent.Flags, TraceState: parent.TraceState}
+			span.ParentSpanID = parent.SpanID
+		} else {
+			span.Context = SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), Flags: traceFlagSampled}
+		}
+
+		ctx := ContextWithSpanContext(request.Context(), span.Context)
+		ctx = logging.WrapCtx(ctx, LoggingCtxTraceID, span.Context.TraceID)
+		ctx = logging.WrapCtx(ctx, LoggingCtxSpanID, span.Context.SpanID)
+		ctx, attempts := withAttemptCounter(ctx)
+		traced := request.WithContext(ctx)
+		// do not mutate the header of the caller's request
+		traced.Header = request.Header.Clone()
+		if traced.Header == nil {
+			traced.Header = make(map[string][]string)
+		}
+		traced.Header.Set(HeaderTraceparent, span.Context.Traceparent())
+		if span.Context.TraceState != "" {
+			traced.Header.Set(HeaderTracestate, span.Context.TraceState)
+		} else {
+			traced.Header.Del(HeaderTracestate)
+		}
+
+		resp, err := next(traced)
+
+		span.EndTime = time.Now()
+		if n := atomic.LoadInt32(attempts); n > 1 {
+			span.Attributes[SpanAttrRetries] = strconv.Itoa(int(n - 1))
+		} else {
+			span.Attributes[SpanAttrRetries] = "0"
+		}
+		if resp != nil {
+			span.Attributes[SpanAttrStatusCode] = strconv.Itoa(resp.Code)
+		}
+		span.Err = err
+		if options.Exporter != nil {
+			options.Exporter.ExportSpan(span)
+		}
+		return resp, err
+	}
+}
+
+func parentFromHeader(request *Request) (SpanContext, bool) {
+	traceparent := request.Header.Get(HeaderTraceparent)
+	if traceparent == "" {
+		return SpanContext{}, false
+	}
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = request.Header.Get(HeaderTracestate)
+	return sc, true
+}
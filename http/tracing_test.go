@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dlshle/gommon/logging"
+	"github.com/dlshle/gommon/retry"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID != "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Fatalf("unexpected span context %+v", sc)
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("unexpected traceparent %s", sc.Traceparent())
+	}
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestTracingInterceptorPropagatesContext(t *testing.T) {
+	var traceparent, tracestate atomic.Value
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent.Store(r.Header.Get(HeaderTraceparent))
+		tracestate.Store(r.Header.Get(HeaderTracestate))
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	var loggingCtx atomic.Value
+	captureLoggingCtx := func(request *Request, next func(*Request) (*Response, error)) (*Response, error) {
+		loggingCtx.Store(request.Context().Value(logging.CtxValLoggingContext))
+		return next(request)
+	}
+	exporter := NewInMemorySpanExporter()
+	c := NewBuilder().MaxConcurrentRequests(1).TimeoutSec(5).
+		AddInterceptor(TracingInterceptor(&TracingOptions{Exporter: exporter})).
+		AddInterceptor(RetryInterceptor(
+			&retry.RetryOptions{MaxRetries: 3, Interval: 10 * time.Millisecond},
+			map[int]bool{http.StatusServiceUnavailable: true},
+		)).
+		AddInterceptor(captureLoggingCtx).
+		Build()
+	defer c.Stop()
+
+	parent := SpanContext{TraceID: NewTraceID(), SpanID: NewSpanID(), Flags: traceFlagSampled, TraceState: "vendor=value"}
+	req, _ := NewRequestBuilder().Context(ContextWithSpanContext(context.Background(), parent)).URL(ts.URL).Build()
+	resp, err := c.Request(req)
+	if err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 response, got %v, %v", resp, err)
+	}
+	if req.Header.Get(HeaderTraceparent) != "" {
+		t.Errorf("expected the caller's request to be left untouched")
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Context.TraceID != parent.TraceID || span.ParentSpanID != parent.SpanID || span.Context.SpanID == parent.SpanID {
+		t.Errorf("span is not a child of the parent: %+v", span)
+	}
+	if span.Attributes[SpanAttrMethod] != http.MethodGet || span.Attributes[SpanAttrURL] != ts.URL {
+		t.Errorf("unexpected attributes %v", span.Attributes)
+	}
+	if span.Attributes[SpanAttrStatusCode] != "200" || span.Attributes[SpanAttrRetries] != "2" {
+		t.Errorf("expected status 200 after 2 retries, got %v", span.Attributes)
+	}
+	if span.Err != nil || span.Duration() <= 0 {
+		t.Errorf("unexpected span result: err %v, duration %s", span.Err, span.Duration())
+	}
+
+	if traceparent.Load() != span.Context.Traceparent() || tracestate.Load() != "vendor=value" {
+		t.Errorf("unexpected propagated headers %v, %v", traceparent.Load(), tracestate.Load())
+	}
+	values, _ := loggingCtx.Load().(map[string]string)
+	if values[LoggingCtxTraceID] != span.Context.TraceID || values[LoggingCtxSpanID] != span.Context.SpanID {
+		t.Errorf("expected trace ids in logging context, got %v", values)
+	}
+}
+
+func TestTracingInterceptorStartsTraceFromHeader(t *testing.T) {
+	exporter := NewInMemorySpanExporter()
+	interceptor := TracingInterceptor(&TracingOptions{Exporter: exporter})
+
+	req, _ := NewRequestBuilder().URL("http://localhost/items").Build()
+	var sent *Request
+	_, err := interceptor(req, func(r *Request) (*Response, error) {
+		sent = r
+		return nil, context.DeadlineExceeded
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the error to pass through, got %v", err)
+	}
+	root := exporter.Spans()[0]
+	if root.ParentSpanID != "" || !root.Context.IsValid() || root.Err != context.DeadlineExceeded {
+		t.Errorf("unexpected root span %+v", root)
+	}
+	if _, ok := root.Attributes[SpanAttrStatusCode]; ok {
+		t.Errorf("failed requests should not record a status code")
+	}
+
+	exporter.Reset()
+	header := http.Header{}
+	header.Set(HeaderTraceparent, sent.Header.Get(HeaderTraceparent))
+	child, _ := NewRequestBuilder().URL("http://localhost/items").Header(header).Build()
+	_, _ = interceptor(child, func(r *Request) (*Response, error) {
+		return &Response{Code: http.StatusOK}, nil
+	})
+	span := exporter.Spans()[0]
+	if span.Context.TraceID != root.Context.TraceID || span.ParentSpanID != root.Context.SpanID {
+		t.Errorf("expected header traceparent to be used as parent, got %+v", span)
+	}
+	// without options spans are propagated but not exported
+	_, err = TracingInterceptor(nil)(child, func(r *Request) (*Response, error) {
+		if r.Header.Get(HeaderTraceparent) == "" {
+			t.Errorf("expected the traceparent header to be propagated")
+		}
+		return &Response{Code: http.StatusOK}, nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
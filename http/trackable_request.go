@@ -1,8 +1,11 @@
 package http
 
 import (
+	"container/list"
 	"context"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/dlshle/gommon/utils"
 )
@@ -13,6 +16,34 @@ type trackableRequest struct {
 	cancelFunc func()
 	request    *http.Request
 	response   *awaitableResponse
+	// queueElement is set while the request waits in the client queue, guarded by the queue's mutex
+	queueElement *list.Element
+	enqueuedAt   time.Time
+	// stopCtxWatch stops dropping the request from the queue once its context is done
+	stopCtxWatch func() bool
+	completeOnce sync.Once
+	// execute replaces the interceptor chain of the client when set
+	execute func(*Request) (*Response, error)
+}
+
+// requestSubmitter queues a request on the client that is executing the current one, execute replaces the
+// client's interceptor chain for it. Interceptors use it to send additional attempts through the worker queue.
+type requestSubmitter func(request *http.Request, execute func(*Request) (*Response, error)) AwaitableResponse
+
+type requestSubmitterKey struct{}
+
+func requestSubmitterFromContext(ctx context.Context) (requestSubmitter, bool) {
+	submitter, ok := ctx.Value(requestSubmitterKey{}).(requestSubmitter)
+	return submitter, ok
+}
+
+type queueWaitKey struct{}
+
+// QueueWait returns how long the request waited in the client queue before a worker picked it up, it is set on
+// the request context the interceptors see.
+func QueueWait(ctx context.Context) (time.Duration, bool) {
+	wait, ok := ctx.Value(queueWaitKey{}).(time.Duration)
+	return wait, ok
 }
 
 type TrackableRequest interface {
@@ -25,6 +56,7 @@ func newTrackableRequest(request *http.Request) *trackableRequest {
 		ctx        context.Context
 		cancelFunc func()
 	)
+	// the timeout starts when the request is submitted, so time spent in the client queue counts toward it
 	if timeout, ok := requestTimeoutContextValue(request.Context()); ok && timeout > 0 {
 		ctx, cancelFunc = context.WithTimeout(request.Context(), timeout)
 	} else {
@@ -32,7 +64,12 @@ func newTrackableRequest(request *http.Request) *trackableRequest {
 	}
 	request = request.WithContext(ctx)
 	id := utils.RandomStringWithSize(12)
-	return &trackableRequest{id, cancelFunc, request, newAwaitableResponse()}
+	return &trackableRequest{
+		id:         id,
+		cancelFunc: cancelFunc,
+		request:    request,
+		response:   newAwaitableResponse(),
+	}
 }
 
 func (tr *trackableRequest) ID() string {
@@ -40,11 +77,16 @@ func (tr *trackableRequest) ID() string {
 }
 
 func (tr *trackableRequest) complete() {
-	// invoke cancel func to release timeout context timer
-	if tr.cancelFunc != nil {
-		tr.cancelFunc()
-		tr.cancelFunc = nil
-	}
+	// streamed responses complete the request when their body is closed, which can race with the worker
+	tr.completeOnce.Do(func() {
+		if tr.stopCtxWatch != nil {
+			tr.stopCtxWatch()
+		}
+		// invoke cancel func to release timeout context timer
+		if tr.cancelFunc != nil {
+			tr.cancelFunc()
+		}
+	})
 }
 
 func (tr *trackableRequest) getRequest() *http.Request {
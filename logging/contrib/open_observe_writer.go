@@ -3,12 +3,9 @@ package contrib
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"fmt"
 	"time"
 
-	nhttp "net/http"
-
 	"github.com/dlshle/gommon/http"
 	"github.com/dlshle/gommon/logging"
 )
@@ -33,19 +30,19 @@ type OpenObserveWriter struct {
 	cancel         context.CancelFunc
 	c              http.HTTPClient
 	streamURL      string
-	hdr            nhttp.Header
 	ch             chan []byte
 	flushThreshold int
 }
 
 func NewOpenObserveWriter(ctx context.Context, cfg *OpenObserveLoggingConfig) logging.LogWriter {
-	c := http.NewBuilder().TimeoutSec(60).MaxConnsPerHost(5).Build()
+	c := http.NewBuilder().TimeoutSec(60).MaxConnsPerHost(5).
+		AddInterceptor(http.BasicAuthInterceptor(cfg.Username, cfg.AccessKey)).
+		Build()
 	innerCtx, cancel := context.WithCancel(ctx)
 	ow := &OpenObserveWriter{
 		ctx:            innerCtx,
 		cancel:         cancel,
 		c:              c,
-		hdr:            http.NewHeaderMaker().Set("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", cfg.Username, cfg.AccessKey))))).Make(),
 		streamURL:      fmt.Sprintf("%s/api/%s/%s/_json", cfg.Host, cfg.Organization, cfg.Stream),
 		ch:             make(chan []byte, 8),
 		flushThreshold: defaultFlushThreshold,
@@ -108,7 +105,6 @@ func (o *OpenObserveWriter) flush(blocks []byte) {
 	req, err := http.NewRequestBuilder().
 		Method("POST").
 		URL(o.streamURL).
-		Header(o.hdr).
 		BytesBody(blocks).
 		Build()
 	if err != nil {
@@ -0,0 +1,304 @@
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	PoolErrPoolClosed  = 1
+	PoolErrGetTimeout  = 2
+	PoolErrNotBorrowed = 3
+	PoolErrInvalidItem = 4
+)
+
+// BorrowOrder decides which idle item is handed out first.
+type BorrowOrder int
+
+const (
+	// LIFO hands out the most recently returned item, keeping a hot working set.
+	LIFO BorrowOrder = 0
+	// FIFO hands out the least recently returned item, spreading usage evenly.
+	FIFO BorrowOrder = 1
+)
+
+type PoolError struct {
+	code uint8
+	msg  string
+}
+
+func NewPoolError(code uint8, msg string) PoolError {
+	return PoolError{code, msg}
+}
+
+func (e PoolError) Error() string {
+	return e.msg
+}
+
+func (e PoolError) Code() uint8 {
+	return e.code
+}
+
+type PoolOptions[T any] struct {
+	// Factory creates a new item. It is required.
+	Factory func() (T, error)
+	// Validate reports whether an item can still be used. Invalid items are destroyed on
+	// borrow and on return. A nil Validate treats every item as valid.
+	Validate func(T) bool
+	// Destroy releases an item that leaves the pool. A nil Destroy is a no-op.
+	Destroy func(T)
+	// MinSize is the number of items created eagerly and replenished after invalid ones are destroyed.
+	MinSize int
+	// MaxSize caps the number of live items (idle + borrowed). Values <= 0 are treated as 1.
+	MaxSize int
+	Order   BorrowOrder
+}
+
+type Pool[T any] interface {
+	Get() (T, error)
+	// GetWithContext waits for an item until one becomes available or ctx is done.
+	GetWithContext(ctx context.Context) (T, error)
+	Return(T) error
+	// Discard destroys a borrowed item instead of returning it to the pool.
+	Discard(T) error
+	Size() int
+	NumIdle() int
+	NumInUse() int
+	IsClosed() bool
+	Close()
+}
+
+type pool[T comparable] struct {
+	mutex    sync.Mutex
+	opts     PoolOptions[T]
+	idle     []T
+	numLive  int
+	numInUse int
+	// borrowed holds the items handed out, so double and foreign returns are rejected
+	borrowed map[T]struct{}
+	closed   bool
+	// notify is closed and replaced whenever an item or a slot becomes available.
+	notify chan struct{}
+}
+
+// NewPool creates a pool and its MinSize items. Items must be distinct values, such as pointers, as borrowed
+// items are told apart by equality.
+func NewPool[T comparable](opts *PoolOptions[T]) (Pool[T], error) {
+	if opts == nil || opts.Factory == nil {
+		return nil, NewPoolError(PoolErrInvalidItem, "pool factory is required")
+	}
+	cfg := *opts
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 1
+	}
+	if cfg.MinSize < 0 {
+		cfg.MinSize = 0
+	} else if cfg.MinSize > cfg.MaxSize {
+		cfg.MinSize = cfg.MaxSize
+	}
+	p := &pool[T]{
+		opts:     cfg,
+		idle:     make([]T, 0, cfg.MaxSize),
+		borrowed: make(map[T]struct{}, cfg.MaxSize),
+		notify:   make(chan struct{}),
+	}
+	if err := p.fill(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// fill creates idle items until the pool holds at least MinSize live items.
+func (p *pool[T]) fill() error {
+	for {
+		p.mutex.Lock()
+		if p.closed || p.numLive >= p.opts.MinSize {
+			p.mutex.Unlock()
+			return nil
+		}
+		p.numLive++
+		p.mutex.Unlock()
+
+		item, err := p.opts.Factory()
+
+		p.mutex.Lock()
+		if err != nil {
+			p.numLive--
+			p.broadcast()
+			p.mutex.Unlock()
+			return err
+		}
+		if p.closed {
+			p.numLive--
+			p.mutex.Unlock()
+			p.destroy(item)
+			return nil
+		}
+		p.idle = append(p.idle, item)
+		p.broadcast()
+		p.mutex.Unlock()
+	}
+}
+
+// broadcast wakes up all waiters, must be called with mutex held.
+func (p *pool[T]) broadcast() {
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
+func (p *pool[T]) destroy(item T) {
+	if p.opts.Destroy != nil {
+		p.opts.Destroy(item)
+	}
+}
+
+func (p *pool[T]) isValid(item T) bool {
+	return p.opts.Validate == nil || p.opts.Validate(item)
+}
+
+// popIdle takes an idle item according to the borrow order, must be called with mutex held.
+func (p *pool[T]) popIdle() T {
+	var (
+		item T
+		zero T
+	)
+	if p.opts.Order == FIFO {
+		item = p.idle[0]
+		p.idle[0] = zero
+		p.idle = p.idle[1:]
+	} else {
+		last := len(p.idle) - 1
+		item = p.idle[last]
+		p.idle[last] = zero
+		p.idle = p.idle[:last]
+	}
+	return item
+}
+
+func (p *pool[T]) Get() (T, error) {
+	return p.GetWithContext(context.Background())
+}
+
+func (p *pool[T]) GetWithContext(ctx context.Context) (item T, err error) {
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return item, NewPoolError(PoolErrPoolClosed, "pool already closed")
+		}
+		if len(p.idle) > 0 {
+			candidate := p.popIdle()
+			p.numInUse++
+			p.borrowed[candidate] = struct{}{}
+			p.mutex.Unlock()
+			if p.isValid(candidate) {
+				return candidate, nil
+			}
+			p.release(candidate, true)
+			continue
+		}
+		if p.numLive < p.opts.MaxSize {
+			p.numLive++
+			p.numInUse++
+			p.mutex.Unlock()
+			candidate, factoryErr := p.opts.Factory()
+			if factoryErr != nil {
+				p.mutex.Lock()
+				p.numLive--
+				p.numInUse--
+				p.broadcast()
+				p.mutex.Unlock()
+				return item, factoryErr
+			}
+			p.mutex.Lock()
+			p.borrowed[candidate] = struct{}{}
+			p.mutex.Unlock()
+			return candidate, nil
+		}
+		notify := p.notify
+		p.mutex.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return item, NewPoolError(PoolErrGetTimeout, "get item timeout: "+ctx.Err().Error())
+		}
+	}
+}
+
+// release takes a borrowed item back, destroying it if it is invalid or the pool is closed.
+func (p *pool[T]) release(item T, invalid bool) error {
+	p.mutex.Lock()
+	if _, ok := p.borrowed[item]; !ok {
+		p.mutex.Unlock()
+		return NewPoolError(PoolErrNotBorrowed, "item is not borrowed from the pool")
+	}
+	delete(p.borrowed, item)
+	p.numInUse--
+	if p.closed || invalid {
+		p.numLive--
+		closed := p.closed
+		p.broadcast()
+		p.mutex.Unlock()
+		p.destroy(item)
+		if closed {
+			return NewPoolError(PoolErrPoolClosed, "pool already closed")
+		}
+		return p.fill()
+	}
+	p.idle = append(p.idle, item)
+	p.broadcast()
+	p.mutex.Unlock()
+	return nil
+}
+
+func (p *pool[T]) Return(item T) error {
+	return p.release(item, !p.isValid(item))
+}
+
+func (p *pool[T]) Discard(item T) error {
+	return p.release(item, true)
+}
+
+func (p *pool[T]) Size() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.numLive
+}
+
+func (p *pool[T]) NumIdle() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.idle)
+}
+
+func (p *pool[T]) NumInUse() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.numInUse
+}
+
+func (p *pool[T]) IsClosed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.closed
+}
+
+// Close destroys all idle items and wakes up waiting borrowers; borrowed items are destroyed when returned.
+func (p *pool[T]) Close() {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.numLive -= len(idle)
+	p.broadcast()
+	p.mutex.Unlock()
+	for _, item := range idle {
+		p.destroy(item)
+	}
+}
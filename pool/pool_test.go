@@ -0,0 +1,218 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	id        int
+	valid     bool
+	destroyed bool
+}
+
+func newTestPool(t *testing.T, minSize, maxSize int, order BorrowOrder) (Pool[*testItem], *int32) {
+	var created int32
+	p, err := NewPool(&PoolOptions[*testItem]{
+		Factory: func() (*testItem, error) {
+			return &testItem{id: int(atomic.AddInt32(&created, 1)), valid: true}, nil
+		},
+		Validate: func(item *testItem) bool { return item.valid },
+		Destroy:  func(item *testItem) { item.destroyed = true },
+		MinSize:  minSize,
+		MaxSize:  maxSize,
+		Order:    order,
+	})
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	return p, &created
+}
+
+func TestPoolCreatesMinSizeEagerly(t *testing.T) {
+	p, created := newTestPool(t, 3, 5, LIFO)
+	defer p.Close()
+	if *created != 3 || p.Size() != 3 || p.NumIdle() != 3 {
+		t.Fatalf("expected 3 eager items, got created=%d size=%d idle=%d", *created, p.Size(), p.NumIdle())
+	}
+}
+
+func TestPoolBorrowOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		order    BorrowOrder
+		expected int
+	}{
+		{"LIFO", LIFO, 2},
+		{"FIFO", FIFO, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _ := newTestPool(t, 0, 2, tc.order)
+			defer p.Close()
+			a, _ := p.Get()
+			b, _ := p.Get()
+			_ = p.Return(a)
+			_ = p.Return(b)
+			item, err := p.Get()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if item.id != tc.expected {
+				t.Errorf("expected item %d, got %d", tc.expected, item.id)
+			}
+		})
+	}
+}
+
+func TestPoolDestroysInvalidItems(t *testing.T) {
+	p, created := newTestPool(t, 1, 1, LIFO)
+	defer p.Close()
+	item, _ := p.Get()
+	item.valid = false
+	if err := p.Return(item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !item.destroyed {
+		t.Error("expected invalid item to be destroyed")
+	}
+	if *created != 2 || p.NumIdle() != 1 {
+		t.Errorf("expected pool to be replenished to min size, created=%d idle=%d", *created, p.NumIdle())
+	}
+	next, _ := p.Get()
+	if next == item {
+		t.Error("expected a fresh item after invalid one was destroyed")
+	}
+}
+
+func TestPoolGetWaitsForReturn(t *testing.T) {
+	p, _ := newTestPool(t, 0, 1, LIFO)
+	defer p.Close()
+	item, _ := p.Get()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var got *testItem
+	go func() {
+		defer wg.Done()
+		got, _ = p.Get()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_ = p.Return(item)
+	wg.Wait()
+	if got != item {
+		t.Errorf("expected waiter to receive the returned item")
+	}
+}
+
+func TestPoolGetWithContextTimeout(t *testing.T) {
+	p, _ := newTestPool(t, 0, 1, LIFO)
+	defer p.Close()
+	_, _ = p.Get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := p.GetWithContext(ctx)
+	var poolErr PoolError
+	if !errors.As(err, &poolErr) || poolErr.Code() != PoolErrGetTimeout {
+		t.Fatalf("expected get timeout error, got %v", err)
+	}
+}
+
+func TestPoolFactoryErrorReleasesSlot(t *testing.T) {
+	fail := true
+	p, err := NewPool(&PoolOptions[int]{
+		Factory: func() (int, error) {
+			if fail {
+				return 0, errors.New("factory failed")
+			}
+			return 1, nil
+		},
+		MaxSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	defer p.Close()
+	if _, err := p.Get(); err == nil {
+		t.Fatal("expected factory error")
+	}
+	fail = false
+	if v, err := p.Get(); err != nil || v != 1 {
+		t.Fatalf("expected item after factory recovers, got %d, %v", v, err)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p, _ := newTestPool(t, 2, 2, LIFO)
+	borrowed, _ := p.Get()
+
+	done := make(chan error)
+	go func() {
+		_, _ = p.Get()
+		_, err := p.Get()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+
+	if err := <-done; err == nil {
+		t.Error("expected waiting Get to fail after close")
+	}
+	if err := p.Return(borrowed); err == nil {
+		t.Error("expected Return to fail after close")
+	}
+	if !borrowed.destroyed {
+		t.Error("expected item returned after close to be destroyed")
+	}
+	if !p.IsClosed() {
+		t.Error("expected pool to be closed")
+	}
+}
+
+func TestPoolReturnWithoutBorrow(t *testing.T) {
+	p, _ := newTestPool(t, 0, 1, LIFO)
+	defer p.Close()
+	err := p.Return(&testItem{valid: true})
+	var poolErr PoolError
+	if !errors.As(err, &poolErr) || poolErr.Code() != PoolErrNotBorrowed {
+		t.Fatalf("expected not borrowed error, got %v", err)
+	}
+}
+
+func TestPoolRejectsDoubleAndForeignReturns(t *testing.T) {
+	p, _ := newTestPool(t, 0, 2, LIFO)
+	defer p.Close()
+	item, _ := p.Get()
+	borrowed, _ := p.Get()
+	if err := p.Return(item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		item *testItem
+	}{
+		{"double", item},
+		{"foreign", &testItem{valid: true}},
+	} {
+		err := p.Return(tc.item)
+		var poolErr PoolError
+		if !errors.As(err, &poolErr) || poolErr.Code() != PoolErrNotBorrowed {
+			t.Errorf("%s: expected not borrowed error, got %v", tc.name, err)
+		}
+	}
+	if p.NumIdle() != 1 || p.NumInUse() != 1 {
+		t.Errorf("expected one idle and one borrowed item, idle=%d in use=%d", p.NumIdle(), p.NumInUse())
+	}
+	if err := p.Return(borrowed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if a == b {
+		t.Error("expected the same item not to be handed to two borrowers")
+	}
+}
@@ -246,6 +246,7 @@ func (n *trieNode) findByPath(path string) *trieNode {
 			}
 			if curr.wildcardChild != nil {
 				curr = curr.wildcardChild
+				remaining = ""
 				break
 			} else if curr.paramChild != nil {
 				curr = curr.paramChild
@@ -322,8 +323,10 @@ func (n *trieNode) match(path string, ctx *MatchContext) (node *trieNode, err er
 				continue
 			}
 			if curr.wildcardChild != nil {
+				// wildcards consume the rest of the path
 				ctx.PathParams[curr.wildcardChild.param] = subPath + remaining
 				curr = curr.wildcardChild
+				remaining = ""
 				break
 			} else if curr.paramChild != nil {
 				ctx.PathParams[curr.paramChild.param] = subPath
@@ -71,6 +71,13 @@ func TestTrieTree(t *testing.T) {
 			}
 			return ctx.PathParams["z"] == "xyz"
 		}),
+		test_utils.NewTestCase("Match wildcard spanning segments", "", func() bool {
+			ctx, err := tree.Match("/x/y/z")
+			if err != nil {
+				return false
+			}
+			return ctx.PathParams["z"] == "x/y/z" && tree.SupportsUri("/x/y/z")
+		}),
 		test_utils.NewTestCase("Add const", "", func() bool {
 			tree.RemoveAll()
 			err := tree.Add("/x/y/z", true, true)
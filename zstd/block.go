@@ -0,0 +1,305 @@
+package zstd
+
+import (
+	"encoding/binary"
+)
+
+// blockDecoder holds the state the compressed blocks of a frame share: the Huffman table and sequence tables
+// later blocks may repeat, and the repeated offsets.
+type blockDecoder struct {
+	huffman        *huffmanTable
+	literalLengths *fseTable
+	offsets        *fseTable
+	matchLengths   *fseTable
+	repeatOffsets  [3]int
+	literals       []byte
+}
+
+func (d *blockDecoder) reset() {
+	d.huffman, d.literalLengths, d.offsets, d.matchLengths = nil, nil, nil, nil
+	d.repeatOffsets = [3]int{1, 4, 8}
+}
+
+// decode appends the content of the compressed block data to window, matches may reach back into window.
+func (d *blockDecoder) decode(window, data []byte) ([]byte, error) {
+	literals, n, err := d.decodeLiterals(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeSequences(window, data[n:], literals)
+}
+
+func (d *blockDecoder) decodeLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, corrupted("missing literals section")
+	}
+	literalsType, sizeFormat := data[0]&3, (data[0]>>2)&3
+	if literalsType == literalsTypeRaw || literalsType == literalsTypeRLE {
+		var size, header int
+		switch sizeFormat {
+		case 0, 2:
+			size, header = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, corrupted("truncated literals header")
+			}
+			size, header = int(data[0]>>4)|int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, corrupted("truncated literals header")
+			}
+			size, header = int(data[0]>>4)|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if size > maxBlockSize {
+			return nil, 0, corrupted("literals exceed the block size")
+		}
+		if literalsType == literalsTypeRaw {
+			if len(data) < header+size {
+				return nil, 0, corrupted("truncated literals")
+			}
+			return data[header : header+size], header + size, nil
+		}
+		if len(data) < header+1 {
+			return nil, 0, corrupted("truncated literals")
+		}
+		d.literals = d.literals[:0]
+		for i := 0; i < size; i++ {
+			d.literals = append(d.literals, data[header])
+		}
+		return d.literals, header + 1, nil
+	}
+
+	streams, header, sizeBits := 4, 3, 10
+	switch sizeFormat {
+	case 0:
+		streams = 1
+	case 2:
+		header, sizeBits = 4, 14
+	case 3:
+		header, sizeBits = 5, 18
+	}
+	if len(data) < header {
+		return nil, 0, corrupted("truncated literals header")
+	}
+	var value uint64
+	for i := header - 1; i >= 0; i-- {
+		value = value<<8 | uint64(data[i])
+	}
+	value >>= 4
+	mask := uint64(1)<<sizeBits - 1
+	size, compressedSize := int(value&mask), int(value>>sizeBits&mask)
+	if size > maxBlockSize {
+		return nil, 0, corrupted("literals exceed the block size")
+	}
+	if len(data) < header+compressedSize {
+		return nil, 0, corrupted("truncated literals")
+	}
+	compressed := data[header : header+compressedSize]
+	if literalsType == literalsTypeHuffman {
+		table, n, err := readHuffmanTable(compressed)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.huffman = table
+		compressed = compressed[n:]
+	} else if d.huffman == nil {
+		return nil, 0, corrupted("treeless literals without a previous Huffman table")
+	}
+	d.literals = d.literals[:0]
+	var err error
+	if streams == 1 {
+		if d.literals, err = d.huffman.decode(d.literals, compressed, size); err != nil {
+			return nil, 0, err
+		}
+		return d.literals, header + compressedSize, nil
+	}
+	if len(compressed) < 6 {
+		return nil, 0, corrupted("truncated literals jump table")
+	}
+	sizes := [4]int{
+		int(binary.LittleEndian.Uint16(compressed[0:])),
+		int(binary.LittleEndian.Uint16(compressed[2:])),
+		int(binary.LittleEndian.Uint16(compressed[4:])),
+	}
+	sizes[3] = len(compressed) - 6 - sizes[0] - sizes[1] - sizes[2]
+	segment := (size + 3) / 4
+	if sizes[3] < 0 || size-3*segment < 0 {
+		return nil, 0, corrupted("invalid literals jump table")
+	}
+	compressed = compressed[6:]
+	for i, streamSize := range sizes {
+		regenerated := segment
+		if i == 3 {
+			regenerated = size - 3*segment
+		}
+		if d.literals, err = d.huffman.decode(d.literals, compressed[:streamSize], regenerated); err != nil {
+			return nil, 0, err
+		}
+		compressed = compressed[streamSize:]
+	}
+	return d.literals, header + compressedSize, nil
+}
+
+// readSequenceTable reads the table of one sequence code for the compression mode of the block.
+func readSequenceTable(data []byte, mode uint8, previous, predefined *fseTable, maxLog, maxSymbol int) (*fseTable, int, error) {
+	switch mode {
+	case 0:
+		return predefined, 0, nil
+	case 1:
+		if len(data) == 0 {
+			return nil, 0, corrupted("truncated sequence table")
+		}
+		if int(data[0]) > maxSymbol {
+			return nil, 0, corrupted("invalid sequence code %d", data[0])
+		}
+		return newRLETable(data[0]), 1, nil
+	case 2:
+		counts, accuracyLog, n, err := readNormalizedCounts(data, maxLog, maxSymbol)
+		if err != nil {
+			return nil, 0, err
+		}
+		table, err := newFSETable(counts, accuracyLog)
+		if err != nil {
+			return nil, 0, err
+		}
+		return table, n, nil
+	default:
+		if previous == nil {
+			return nil, 0, corrupted("repeated sequence table without a previous table")
+		}
+		return previous, 0, nil
+	}
+}
+
+func (d *blockDecoder) decodeSequences(window, data, literals []byte) ([]byte, error) {
+	blockStart := len(window)
+	if len(data) == 0 {
+		return nil, corrupted("missing sequences section")
+	}
+	count, n := int(data[0]), 1
+	switch {
+	case count == 255:
+		if len(data) < 3 {
+			return nil, corrupted("truncated sequences header")
+		}
+		count, n = (int(data[1])|int(data[2])<<8)+0x7F00, 3
+	case count >= 128:
+		if len(data) < 2 {
+			return nil, corrupted("truncated sequences header")
+		}
+		count, n = (count-128)<<8|int(data[1]), 2
+	}
+	data = data[n:]
+	if count == 0 {
+		if len(data) != 0 {
+			return nil, corrupted("trailing data after the sequences header")
+		}
+		return append(window, literals...), nil
+	}
+	if len(data) == 0 {
+		return nil, corrupted("missing sequence compression modes")
+	}
+	modes := data[0]
+	if modes&3 != 0 {
+		return nil, corrupted("reserved sequence compression mode bits are set")
+	}
+	data = data[1:]
+	var err error
+	if d.literalLengths, n, err = readSequenceTable(data, modes>>6, d.literalLengths, predefinedLiteralLengthTable,
+		maxLiteralLengthLog, maxLiteralLengthCode); err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	if d.offsets, n, err = readSequenceTable(data, (modes>>4)&3, d.offsets, predefinedOffsetTable,
+		maxOffsetLog, maxOffsetCode); err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	if d.matchLengths, n, err = readSequenceTable(data, (modes>>2)&3, d.matchLengths, predefinedMatchLengthTable,
+		maxMatchLengthLog, maxMatchLengthCode); err != nil {
+		return nil, err
+	}
+	data = data[n:]
+
+	r, err := newBackwardBitReader(data)
+	if err != nil {
+		return nil, err
+	}
+	var literalLengths, offsets, matchLengths fseDecoder
+	literalLengths.init(&r, d.literalLengths)
+	offsets.init(&r, d.offsets)
+	matchLengths.init(&r, d.matchLengths)
+	for i := 0; i < count; i++ {
+		offsetCode, matchLengthCode, literalLengthCode := offsets.symbol(), matchLengths.symbol(), literalLengths.symbol()
+		if offsetCode > maxOffsetCode || matchLengthCode > maxMatchLengthCode || literalLengthCode > maxLiteralLengthCode {
+			return nil, corrupted("invalid sequence code")
+		}
+		offsetValue := 1<<offsetCode + int(r.read(int(offsetCode)))
+		matchLength := int(matchLengthBaselines[matchLengthCode]) + int(r.read(int(matchLengthBits[matchLengthCode])))
+		literalLength := int(literalLengthBaselines[literalLengthCode]) + int(r.read(int(literalLengthBits[literalLengthCode])))
+		if i != count-1 {
+			literalLengths.update(&r)
+			matchLengths.update(&r)
+			offsets.update(&r)
+		}
+		if r.bits < 0 {
+			return nil, corrupted("sequences bitstream overflow")
+		}
+
+		if literalLength > len(literals) {
+			return nil, corrupted("sequence literal length exceeds the literals")
+		}
+		window = append(window, literals[:literalLength]...)
+		literals = literals[literalLength:]
+
+		offset := d.resolveOffset(offsetValue, literalLength == 0)
+		if offset <= 0 || offset > len(window) {
+			return nil, corrupted("sequence offset %d out of the window", offset)
+		}
+		if len(window)-blockStart+matchLength > maxBlockSize {
+			return nil, corrupted("block content exceeds the block size")
+		}
+		start := len(window) - offset
+		if offset >= matchLength {
+			window = append(window, window[start:start+matchLength]...)
+		} else {
+			// the match overlaps what it produces
+			for j := 0; j < matchLength; j++ {
+				window = append(window, window[start+j])
+			}
+		}
+	}
+	if r.bits != 0 {
+		return nil, corrupted("sequences bitstream size mismatch")
+	}
+	if len(window)-blockStart+len(literals) > maxBlockSize {
+		return nil, corrupted("block content exceeds the block size")
+	}
+	return append(window, literals...), nil
+}
+
+// resolveOffset turns an offset value into an offset and updates the repeated offsets. Values 1 to 3 repeat
+// a recent offset, shifted by one when the sequence has no literals.
+func (d *blockDecoder) resolveOffset(value int, noLiterals bool) int {
+	rep := &d.repeatOffsets
+	if value > 3 {
+		offset := value - 3
+		rep[0], rep[1], rep[2] = offset, rep[0], rep[1]
+		return offset
+	}
+	index := value - 1
+	if noLiterals {
+		index++
+	}
+	switch index {
+	case 0:
+		return rep[0]
+	case 1:
+		rep[0], rep[1] = rep[1], rep[0]
+	case 2:
+		rep[0], rep[1], rep[2] = rep[2], rep[0], rep[1]
+	default:
+		rep[0], rep[1], rep[2] = rep[0]-1, rep[0], rep[1]
+	}
+	return rep[0]
+}
@@ -0,0 +1,348 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+// forwardBitReader reads little-endian bits from the start of data, bits past the end read as zero.
+type forwardBitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *forwardBitReader) peek(n int) uint64 {
+	var word uint64
+	start := r.pos >> 3
+	for i := 0; i < 8 && start+i < len(r.data); i++ {
+		word |= uint64(r.data[start+i]) << (8 * i)
+	}
+	return (word >> uint(r.pos&7)) & (1<<uint(n) - 1)
+}
+
+func (r *forwardBitReader) skip(n int) {
+	r.pos += n
+}
+
+// backwardBitReader reads a bitstream from its end, the way FSE and Huffman streams are written. The highest set
+// bit of the last byte marks where the stream starts. Reading past the start yields zeros and leaves bits
+// negative, which decoders use to tell an overconsumed stream.
+type backwardBitReader struct {
+	data []byte
+	bits int
+}
+
+func newBackwardBitReader(data []byte) (backwardBitReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return backwardBitReader{}, corrupted("missing bitstream start marker")
+	}
+	return backwardBitReader{data: data, bits: len(data)*8 - 9 + bits.Len8(data[len(data)-1])}, nil
+}
+
+// peek returns the next n bits, at most 56, without consuming them.
+func (r *backwardBitReader) peek(n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+	low := r.bits - n
+	shift := 0
+	if low < 0 {
+		shift, low = -low, 0
+	}
+	var word uint64
+	start := low >> 3
+	for i := 0; i < 8 && start+i < len(r.data); i++ {
+		word |= uint64(r.data[start+i]) << (8 * i)
+	}
+	word >>= uint(low & 7)
+	if shift >= 64 {
+		return 0
+	}
+	return (word << uint(shift)) & (1<<uint(n) - 1)
+}
+
+func (r *backwardBitReader) read(n int) uint64 {
+	value := r.peek(n)
+	r.bits -= n
+	return value
+}
+
+// readNormalizedCounts reads an FSE table description from the start of data, it returns the normalized count
+// of every symbol, the accuracy log and the size of the description in bytes.
+func readNormalizedCounts(data []byte, maxLog, maxSymbol int) ([]int16, int, int, error) {
+	if len(data) == 0 {
+		return nil, 0, 0, corrupted("missing FSE table description")
+	}
+	r := &forwardBitReader{data: data}
+	accuracyLog := int(r.peek(4)) + 5
+	r.skip(4)
+	if accuracyLog > maxLog {
+		return nil, 0, 0, corrupted("FSE accuracy log %d exceeds %d", accuracyLog, maxLog)
+	}
+	counts := make([]int16, 0, maxSymbol+1)
+	remaining := 1<<accuracyLog + 1
+	threshold := 1 << accuracyLog
+	nbBits := accuracyLog + 1
+	previousZero := false
+	for remaining > 1 && len(counts) <= maxSymbol {
+		if previousZero {
+			// a zero count is followed by 2 bit flags repeating it, a flag of 3 continues the repetition
+			for {
+				repeat := int(r.peek(2))
+				r.skip(2)
+				for i := 0; i < repeat; i++ {
+					counts = append(counts, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+			if len(counts) > maxSymbol {
+				return nil, 0, 0, corrupted("FSE table describes too many symbols")
+			}
+		}
+		max := 2*threshold - 1 - remaining
+		var count int
+		if low := int(r.peek(nbBits - 1)); low < max {
+			count = low
+			r.skip(nbBits - 1)
+		} else {
+			count = int(r.peek(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+			r.skip(nbBits)
+		}
+		count--
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		counts = append(counts, int16(count))
+		previousZero = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || r.pos > len(data)*8 {
+		return nil, 0, 0, corrupted("invalid FSE table description")
+	}
+	return counts, accuracyLog, (r.pos + 7) >> 3, nil
+}
+
+type fseEntry struct {
+	symbol   uint8
+	bits     uint8
+	baseline uint16
+}
+
+// fseTable is an FSE decoding table, the state indexes entries.
+type fseTable struct {
+	accuracyLog int
+	entries     []fseEntry
+}
+
+// spreadSymbols lays the symbols out over the states of a table, symbols with a probability below 1 take
+// the last states.
+func spreadSymbols(counts []int16, accuracyLog int) ([]uint8, error) {
+	size := 1 << accuracyLog
+	symbols := make([]uint8, size)
+	high := size - 1
+	for symbol, count := range counts {
+		if count == -1 {
+			symbols[high] = uint8(symbol)
+			high--
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	position := 0
+	for symbol, count := range counts {
+		for i := 0; i < int(count); i++ {
+			symbols[position] = uint8(symbol)
+			position = (position + step) & mask
+			for position > high {
+				position = (position + step) & mask
+			}
+		}
+	}
+	if position != 0 {
+		return nil, corrupted("FSE counts do not fill the table")
+	}
+	return symbols, nil
+}
+
+func newFSETable(counts []int16, accuracyLog int) (*fseTable, error) {
+	symbols, err := spreadSymbols(counts, accuracyLog)
+	if err != nil {
+		return nil, err
+	}
+	size := 1 << accuracyLog
+	next := make([]int, len(counts))
+	for symbol, count := range counts {
+		if count == -1 {
+			next[symbol] = 1
+		} else {
+			next[symbol] = int(count)
+		}
+	}
+	table := &fseTable{accuracyLog: accuracyLog, entries: make([]fseEntry, size)}
+	for state, symbol := range symbols {
+		nextState := next[symbol]
+		next[symbol]++
+		nbBits := accuracyLog - (bits.Len(uint(nextState)) - 1)
+		table.entries[state] = fseEntry{
+			symbol:   symbol,
+			bits:     uint8(nbBits),
+			baseline: uint16(nextState<<nbBits - size),
+		}
+	}
+	return table, nil
+}
+
+// newRLETable returns the table of a single symbol, which takes no bits to decode.
+func newRLETable(symbol uint8) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+func mustFSETable(counts []int16, accuracyLog int) *fseTable {
+	table, err := newFSETable(counts, accuracyLog)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+var (
+	predefinedLiteralLengthTable = mustFSETable(predefinedLiteralLengths, predefinedLiteralLengthLog)
+	predefinedMatchLengthTable   = mustFSETable(predefinedMatchLengths, predefinedMatchLengthLog)
+	predefinedOffsetTable        = mustFSETable(predefinedOffsets, predefinedOffsetLog)
+)
+
+type fseDecoder struct {
+	table *fseTable
+	state int
+}
+
+func (d *fseDecoder) init(r *backwardBitReader, table *fseTable) {
+	d.table = table
+	d.state = int(r.read(table.accuracyLog))
+}
+
+func (d *fseDecoder) symbol() uint8 {
+	return d.table.entries[d.state].symbol
+}
+
+func (d *fseDecoder) update(r *backwardBitReader) {
+	entry := d.table.entries[d.state]
+	d.state = int(entry.baseline) + int(r.read(int(entry.bits)))
+}
+
+type fseSymbolTransform struct {
+	deltaBits  int32
+	deltaState int32
+}
+
+// fseEncodingTable is the encoding counterpart of the FSE decoding table built from the same counts.
+type fseEncodingTable struct {
+	accuracyLog int
+	states      []uint16
+	transforms  []fseSymbolTransform
+}
+
+func newFSEEncodingTable(counts []int16, accuracyLog int) *fseEncodingTable {
+	symbols, err := spreadSymbols(counts, accuracyLog)
+	if err != nil {
+		panic(err)
+	}
+	size := 1 << accuracyLog
+	cumulative := make([]int, len(counts)+1)
+	for symbol, count := range counts {
+		if count == -1 {
+			count = 1
+		}
+		cumulative[symbol+1] = cumulative[symbol] + int(count)
+	}
+	t := &fseEncodingTable{accuracyLog: accuracyLog, states: make([]uint16, size), transforms: make([]fseSymbolTransform, len(counts))}
+	for state, symbol := range symbols {
+		t.states[cumulative[symbol]] = uint16(size + state)
+		cumulative[symbol]++
+	}
+	total := 0
+	for symbol, count := range counts {
+		switch count {
+		case 0:
+			t.transforms[symbol] = fseSymbolTransform{deltaBits: int32((accuracyLog+1)<<16 - size)}
+		case -1, 1:
+			t.transforms[symbol] = fseSymbolTransform{deltaBits: int32(accuracyLog<<16 - size), deltaState: int32(total - 1)}
+			total++
+		default:
+			maxBitsOut := accuracyLog - (bits.Len(uint(count-1)) - 1)
+			minStatePlus := int(count) << maxBitsOut
+			t.transforms[symbol] = fseSymbolTransform{deltaBits: int32(maxBitsOut<<16 - minStatePlus), deltaState: int32(total - int(count))}
+			total += int(count)
+		}
+	}
+	return t
+}
+
+var (
+	predefinedLiteralLengthEncoding = newFSEEncodingTable(predefinedLiteralLengths, predefinedLiteralLengthLog)
+	predefinedMatchLengthEncoding   = newFSEEncodingTable(predefinedMatchLengths, predefinedMatchLengthLog)
+	predefinedOffsetEncoding        = newFSEEncodingTable(predefinedOffsets, predefinedOffsetLog)
+)
+
+// fseEncoder encodes symbols from the last to the first, so the decoder reads them in order.
+type fseEncoder struct {
+	table *fseEncodingTable
+	value uint32
+}
+
+// begin sets the state to the last symbol of the stream, the first to be encoded.
+func (e *fseEncoder) begin(table *fseEncodingTable, symbol uint8) {
+	e.table = table
+	transform := table.transforms[symbol]
+	nbBits := uint32(transform.deltaBits+1<<15) >> 16
+	value := nbBits<<16 - uint32(transform.deltaBits)
+	e.value = uint32(table.states[int32(value>>nbBits)+transform.deltaState])
+}
+
+func (e *fseEncoder) encode(w *bitWriter, symbol uint8) {
+	transform := e.table.transforms[symbol]
+	nbBits := (e.value + uint32(transform.deltaBits)) >> 16
+	w.addBits(uint64(e.value), uint(nbBits))
+	e.value = uint32(e.table.states[int32(e.value>>nbBits)+transform.deltaState])
+}
+
+// flush writes the state the decoder starts from.
+func (e *fseEncoder) flush(w *bitWriter) {
+	w.addBits(uint64(e.value), uint(e.table.accuracyLog))
+}
+
+// bitWriter writes little-endian bits, the stream is read back to front by backwardBitReader.
+type bitWriter struct {
+	out   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) addBits(value uint64, n uint) {
+	w.acc |= (value & (1<<n - 1)) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.out = append(w.out, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+// close marks the start of the stream for the reader and pads it to a whole byte.
+func (w *bitWriter) close() []byte {
+	w.addBits(1, 1)
+	if w.nbits > 0 {
+		w.out = append(w.out, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.out
+}
@@ -0,0 +1,158 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+const (
+	maxHuffmanBits       = 11
+	maxHuffmanWeightsLog = 6
+	maxHuffmanSymbols    = 256
+)
+
+type huffmanEntry struct {
+	symbol uint8
+	bits   uint8
+}
+
+// huffmanTable decodes a prefix code by looking up the next maxBits bits.
+type huffmanTable struct {
+	maxBits int
+	entries []huffmanEntry
+}
+
+// readHuffmanTable reads a Huffman tree description from the start of data, it returns the table and the size
+// of the description in bytes.
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, corrupted("missing Huffman tree description")
+	}
+	var (
+		weights []uint8
+		size    int
+	)
+	if header := int(data[0]); header >= 128 {
+		// weights are stored directly, 4 bits each
+		count := header - 127
+		size = 1 + (count+1)/2
+		if len(data) < size {
+			return nil, 0, corrupted("truncated Huffman weights")
+		}
+		weights = make([]uint8, count)
+		for i := range weights {
+			if i%2 == 0 {
+				weights[i] = data[1+i/2] >> 4
+			} else {
+				weights[i] = data[1+i/2] & 0xf
+			}
+		}
+	} else {
+		size = 1 + header
+		if len(data) < size {
+			return nil, 0, corrupted("truncated Huffman weights")
+		}
+		var err error
+		if weights, err = decodeHuffmanWeights(data[1:size]); err != nil {
+			return nil, 0, err
+		}
+	}
+	table, err := newHuffmanTable(weights)
+	if err != nil {
+		return nil, 0, err
+	}
+	return table, size, nil
+}
+
+// decodeHuffmanWeights decodes FSE compressed weights, two interleaved states share the bitstream.
+func decodeHuffmanWeights(data []byte) ([]uint8, error) {
+	counts, accuracyLog, n, err := readNormalizedCounts(data, maxHuffmanWeightsLog, maxHuffmanBits+1)
+	if err != nil {
+		return nil, err
+	}
+	table, err := newFSETable(counts, accuracyLog)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newBackwardBitReader(data[n:])
+	if err != nil {
+		return nil, err
+	}
+	var states [2]fseDecoder
+	states[0].init(&r, table)
+	states[1].init(&r, table)
+	weights := make([]uint8, 0, maxHuffmanSymbols)
+	for i := 0; ; i ^= 1 {
+		if len(weights) >= maxHuffmanSymbols-1 {
+			return nil, corrupted("too many Huffman weights")
+		}
+		weights = append(weights, states[i].symbol())
+		states[i].update(&r)
+		if r.bits < 0 {
+			// the stream ends with the symbol of the other state
+			weights = append(weights, states[i^1].symbol())
+			return weights, nil
+		}
+	}
+}
+
+func newHuffmanTable(weights []uint8) (*huffmanTable, error) {
+	if len(weights) >= maxHuffmanSymbols {
+		return nil, corrupted("too many Huffman weights")
+	}
+	total := 0
+	for _, weight := range weights {
+		if weight > maxHuffmanBits {
+			return nil, corrupted("Huffman weight %d exceeds %d", weight, maxHuffmanBits)
+		}
+		if weight > 0 {
+			total += 1 << (weight - 1)
+		}
+	}
+	if total == 0 {
+		return nil, corrupted("Huffman weights are all zero")
+	}
+	maxBits := bits.Len(uint(total))
+	if maxBits > maxHuffmanBits {
+		return nil, corrupted("Huffman code of %d bits exceeds %d", maxBits, maxHuffmanBits)
+	}
+	// the weight of the last symbol completes the total to a power of two
+	rest := 1<<maxBits - total
+	last := bits.Len(uint(rest))
+	if rest&(rest-1) != 0 {
+		return nil, corrupted("Huffman weights do not complete to a power of two")
+	}
+	weights = append(weights, uint8(last))
+	table := &huffmanTable{maxBits: maxBits, entries: make([]huffmanEntry, 1<<maxBits)}
+	position := 0
+	for weight := 1; weight <= maxBits; weight++ {
+		for symbol, w := range weights {
+			if int(w) != weight {
+				continue
+			}
+			length := 1 << (weight - 1)
+			entry := huffmanEntry{symbol: uint8(symbol), bits: uint8(maxBits + 1 - weight)}
+			for i := position; i < position+length; i++ {
+				table.entries[i] = entry
+			}
+			position += length
+		}
+	}
+	return table, nil
+}
+
+// decode appends the size symbols of the Huffman coded stream data to out.
+func (t *huffmanTable) decode(out, data []byte, size int) ([]byte, error) {
+	r, err := newBackwardBitReader(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < size; i++ {
+		entry := t.entries[r.peek(t.maxBits)]
+		out = append(out, entry.symbol)
+		r.bits -= int(entry.bits)
+	}
+	if r.bits != 0 {
+		return nil, corrupted("Huffman stream size mismatch")
+	}
+	return out, nil
+}
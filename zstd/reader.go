@@ -0,0 +1,240 @@
+package zstd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Reader decompresses a stream of Zstandard frames, skippable frames are ignored.
+type Reader struct {
+	r   *bufio.Reader
+	err error
+	// inFrame is set between the header and the last block of a frame
+	inFrame     bool
+	lastBlock   bool
+	windowSize  int
+	checksum    bool
+	contentSize int64
+	produced    int64
+	hash        xxhash64
+	blocks      blockDecoder
+	// window holds the content of the frame matches may refer to, followed by the content not read yet
+	window  []byte
+	pending int
+	block   []byte
+}
+
+// NewReader returns a Reader decompressing r, it does not close r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	for z.pending == len(z.window) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.window[z.pending:])
+	z.pending += n
+	return n, nil
+}
+
+// Close releases the buffers of the Reader, further reads fail.
+func (z *Reader) Close() error {
+	z.window, z.block, z.pending = nil, nil, 0
+	if z.err == nil {
+		z.err = errors.New("zstd: read from a closed reader")
+	}
+	return nil
+}
+
+// next decodes the next block, reading the header of the next frame if needed.
+func (z *Reader) next() error {
+	if !z.inFrame {
+		if err := z.readFrameHeader(); err != nil {
+			return err
+		}
+	}
+	if z.lastBlock {
+		return z.endFrame()
+	}
+	var header [3]byte
+	if _, err := io.ReadFull(z.r, header[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	value := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	z.lastBlock = value&1 == 1
+	blockType, size := (value>>1)&3, value>>3
+	maxSize := min(z.windowSize, maxBlockSize)
+	if blockType != blockTypeRLE && size > maxSize {
+		return corrupted("block of %d bytes exceeds the maximum of %d", size, maxSize)
+	}
+
+	// matches only reach windowSize back, older content is dropped once the window has grown twice as large
+	if len(z.window) > 2*max(z.windowSize, maxBlockSize) {
+		z.window = append(z.window[:0], z.window[len(z.window)-z.windowSize:]...)
+	}
+	start := len(z.window)
+	switch blockType {
+	case blockTypeRaw:
+		z.window = append(z.window, make([]byte, size)...)
+		if _, err := io.ReadFull(z.r, z.window[start:]); err != nil {
+			return unexpectedEOF(err)
+		}
+	case blockTypeRLE:
+		if size > maxSize {
+			return corrupted("block of %d bytes exceeds the maximum of %d", size, maxSize)
+		}
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		for i := 0; i < size; i++ {
+			z.window = append(z.window, b)
+		}
+	case blockTypeCompressed:
+		if cap(z.block) < size {
+			z.block = make([]byte, size)
+		}
+		z.block = z.block[:size]
+		if _, err := io.ReadFull(z.r, z.block); err != nil {
+			return unexpectedEOF(err)
+		}
+		window, err := z.blocks.decode(z.window, z.block)
+		if err != nil {
+			return err
+		}
+		z.window = window
+	default:
+		return corrupted("reserved block type")
+	}
+	z.pending = start
+	z.produced += int64(len(z.window) - start)
+	if z.checksum {
+		z.hash.write(z.window[start:])
+	}
+	return nil
+}
+
+func (z *Reader) readFrameHeader() error {
+	var magic [4]byte
+	for {
+		if _, err := io.ReadFull(z.r, magic[:]); err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return unexpectedEOF(err)
+		}
+		value := binary.LittleEndian.Uint32(magic[:])
+		if value == frameMagic {
+			break
+		}
+		if value&skippableMagicMask != skippableFrameMagic {
+			return ErrInvalidFrameMagic
+		}
+		if _, err := io.ReadFull(z.r, magic[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		if _, err := io.CopyN(io.Discard, z.r, int64(binary.LittleEndian.Uint32(magic[:]))); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	descriptor, err := z.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if descriptor&0x08 != 0 {
+		return corrupted("reserved frame header bit is set")
+	}
+	singleSegment := descriptor&0x20 != 0
+	dictionaryIDSize := [4]int{0, 1, 2, 4}[descriptor&3]
+	contentSizeSize := [4]int{0, 2, 4, 8}[descriptor>>6]
+	if singleSegment && contentSizeSize == 0 {
+		contentSizeSize = 1
+	}
+	windowDescriptorSize := 1
+	if singleSegment {
+		windowDescriptorSize = 0
+	}
+	var fields [13]byte
+	header := fields[:windowDescriptorSize+dictionaryIDSize+contentSizeSize]
+	if _, err = io.ReadFull(z.r, header); err != nil {
+		return unexpectedEOF(err)
+	}
+	if windowDescriptorSize == 1 {
+		exponent, mantissa := int(header[0]>>3), int(header[0]&7)
+		if exponent > 31-10 {
+			return ErrWindowTooLarge
+		}
+		base := 1 << (10 + exponent)
+		z.windowSize = base + base/8*mantissa
+	}
+	var dictionaryID uint32
+	for i, b := range header[windowDescriptorSize : windowDescriptorSize+dictionaryIDSize] {
+		dictionaryID |= uint32(b) << (8 * i)
+	}
+	if dictionaryID != 0 {
+		return ErrDictionary
+	}
+	z.contentSize = -1
+	if contentSizeSize > 0 {
+		var contentSize uint64
+		for i, b := range header[windowDescriptorSize+dictionaryIDSize:] {
+			contentSize |= uint64(b) << (8 * i)
+		}
+		if contentSizeSize == 2 {
+			contentSize += 256
+		}
+		if contentSize > 1<<62 {
+			return corrupted("frame content size overflows")
+		}
+		z.contentSize = int64(contentSize)
+	}
+	if singleSegment {
+		if z.contentSize > MaxWindowSize {
+			return ErrWindowTooLarge
+		}
+		z.windowSize = int(z.contentSize)
+	}
+	if z.windowSize > MaxWindowSize {
+		return ErrWindowTooLarge
+	}
+
+	z.inFrame, z.lastBlock = true, false
+	z.checksum = descriptor&0x04 != 0
+	z.produced = 0
+	z.hash.reset()
+	z.blocks.reset()
+	z.window, z.pending = z.window[:0], 0
+	return nil
+}
+
+func (z *Reader) endFrame() error {
+	z.inFrame = false
+	if z.contentSize >= 0 && z.produced != z.contentSize {
+		return corrupted("frame content size mismatch")
+	}
+	if !z.checksum {
+		return nil
+	}
+	var checksum [4]byte
+	if _, err := io.ReadFull(z.r, checksum[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if binary.LittleEndian.Uint32(checksum[:]) != uint32(z.hash.sum()) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,276 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+)
+
+const (
+	writerWindowLog  = 20
+	writerWindowSize = 1 << writerWindowLog
+	writerHashLog    = 16
+	minMatch         = 4
+)
+
+type sequence struct {
+	literalLength int
+	matchLength   int
+	offset        int
+}
+
+// Writer compresses data into a single Zstandard frame with a content checksum, content is buffered until a
+// full block is available or the Writer is closed.
+type Writer struct {
+	w      io.Writer
+	err    error
+	closed bool
+	// wroteHeader is set once the frame header has been written
+	wroteHeader bool
+	hash        xxhash64
+	// history holds the previous content matches may refer to, followed by the content of the next block
+	history    []byte
+	blockStart int
+	// table maps the hash of 4 bytes to their last position in history plus one
+	table     []int32
+	literals  []byte
+	sequences []sequence
+	out       []byte
+}
+
+// NewWriter returns a Writer compressing to w, Close must be called to complete the frame. It does not close w.
+func NewWriter(w io.Writer) *Writer {
+	z := &Writer{w: w, table: make([]int32, 1<<writerHashLog)}
+	z.hash.reset()
+	return z
+}
+
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, ErrWriterClosed
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full block is only written once more content follows, so Close can mark the last block
+		if len(z.history)-z.blockStart == maxBlockSize {
+			if z.err = z.writeBlock(false); z.err != nil {
+				return written, z.err
+			}
+		}
+		n := min(len(p), maxBlockSize-(len(z.history)-z.blockStart))
+		z.history = append(z.history, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the buffered content and the end of the frame, it does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err != nil {
+		return z.err
+	}
+	if z.err = z.writeBlock(true); z.err != nil {
+		return z.err
+	}
+	var checksum [4]byte
+	binary.LittleEndian.PutUint32(checksum[:], uint32(z.hash.sum()))
+	_, z.err = z.w.Write(checksum[:])
+	return z.err
+}
+
+func (z *Writer) writeBlock(last bool) error {
+	z.out = z.out[:0]
+	if !z.wroteHeader {
+		z.wroteHeader = true
+		// the descriptor only sets the checksum flag, the window descriptor has a zero mantissa
+		z.out = binary.LittleEndian.AppendUint32(z.out, frameMagic)
+		z.out = append(z.out, 0x04, (writerWindowLog-10)<<3)
+	}
+	content := z.history[z.blockStart:]
+	z.hash.write(content)
+	header := len(z.out)
+	z.out = append(z.out, 0, 0, 0)
+	blockType, size := blockTypeRaw, len(content)
+	switch {
+	case len(content) > 1 && isRun(content):
+		blockType = blockTypeRLE
+		z.out = append(z.out, content[0])
+	case z.compressBlock():
+		blockType, size = blockTypeCompressed, len(z.out)-header-3
+	default:
+		z.out = append(z.out[:header+3], content...)
+	}
+	value := size<<3 | blockType<<1
+	if last {
+		value |= 1
+	}
+	z.out[header], z.out[header+1], z.out[header+2] = byte(value), byte(value>>8), byte(value>>16)
+	if _, err := z.w.Write(z.out); err != nil {
+		return err
+	}
+	z.blockStart = len(z.history)
+	z.slide()
+	return nil
+}
+
+// slide drops the history matches can no longer reach once it has grown past twice the window.
+func (z *Writer) slide() {
+	if len(z.history) <= 2*writerWindowSize {
+		return
+	}
+	delta := len(z.history) - writerWindowSize
+	z.history = append(z.history[:0], z.history[delta:]...)
+	z.blockStart -= delta
+	for i, position := range z.table {
+		if int(position) > delta {
+			z.table[i] = position - int32(delta)
+		} else {
+			z.table[i] = 0
+		}
+	}
+}
+
+func isRun(content []byte) bool {
+	for _, b := range content[1:] {
+		if b != content[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func hash4(value uint32) uint32 {
+	return (value * 2654435761) >> (32 - writerHashLog)
+}
+
+// compressBlock appends the block being written to out as a compressed block, it reports false when that
+// would not be smaller than the content.
+func (z *Writer) compressBlock() bool {
+	h := z.history
+	start, end := z.blockStart, len(z.history)
+	z.literals, z.sequences = z.literals[:0], z.sequences[:0]
+	literalStart := start
+	for i := start; i+minMatch <= end; {
+		value := binary.LittleEndian.Uint32(h[i:])
+		slot := hash4(value)
+		candidate := int(z.table[slot]) - 1
+		z.table[slot] = int32(i + 1)
+		if candidate < 0 || i-candidate > writerWindowSize || binary.LittleEndian.Uint32(h[candidate:]) != value {
+			// step faster through content that does not match
+			i += 1 + (i-literalStart)>>6
+			continue
+		}
+		length := minMatch
+		for i+length < end && h[candidate+length] == h[i+length] {
+			length++
+		}
+		for i > literalStart && candidate > 0 && h[i-1] == h[candidate-1] {
+			i, candidate, length = i-1, candidate-1, length+1
+		}
+		z.literals = append(z.literals, h[literalStart:i]...)
+		z.sequences = append(z.sequences, sequence{literalLength: i - literalStart, matchLength: length, offset: i - candidate})
+		i += length
+		literalStart = i
+		if i+minMatch-2 <= end {
+			z.table[hash4(binary.LittleEndian.Uint32(h[i-2:]))] = int32(i - 1)
+		}
+	}
+	if len(z.sequences) == 0 {
+		return false
+	}
+	z.literals = append(z.literals, h[literalStart:end]...)
+	header := len(z.out)
+	z.out = appendRawLiterals(z.out, z.literals)
+	z.out = appendSequences(z.out, z.sequences)
+	if len(z.out)-header >= end-start {
+		z.out = z.out[:header]
+		return false
+	}
+	return true
+}
+
+func appendRawLiterals(out, literals []byte) []byte {
+	size := len(literals)
+	switch {
+	case size < 32:
+		out = append(out, byte(size<<3))
+	case size < 4096:
+		out = append(out, byte(size<<4|1<<2), byte(size>>4))
+	default:
+		out = append(out, byte(size<<4|3<<2), byte(size>>4), byte(size>>12))
+	}
+	return append(out, literals...)
+}
+
+// appendSequences encodes sequences with the predefined codes, the bitstream is written from the last sequence
+// to the first so the decoder reads it in order.
+func appendSequences(out []byte, sequences []sequence) []byte {
+	count := len(sequences)
+	switch {
+	case count < 128:
+		out = append(out, byte(count))
+	case count < 0x7F00:
+		out = append(out, byte(count>>8+128), byte(count))
+	default:
+		out = append(out, 255, byte(count-0x7F00), byte((count-0x7F00)>>8))
+	}
+	out = append(out, 0)
+
+	w := &bitWriter{out: out}
+	var literalLengths, offsets, matchLengths fseEncoder
+	last := sequences[count-1]
+	literalLengthCode, matchLengthCode, offsetCode := literalLengthCodeOf(last.literalLength),
+		matchLengthCodeOf(last.matchLength), offsetCodeOf(last.offset)
+	matchLengths.begin(predefinedMatchLengthEncoding, matchLengthCode)
+	offsets.begin(predefinedOffsetEncoding, offsetCode)
+	literalLengths.begin(predefinedLiteralLengthEncoding, literalLengthCode)
+	appendExtraBits(w, last, literalLengthCode, matchLengthCode, offsetCode)
+	for i := count - 2; i >= 0; i-- {
+		s := sequences[i]
+		literalLengthCode, matchLengthCode, offsetCode = literalLengthCodeOf(s.literalLength),
+			matchLengthCodeOf(s.matchLength), offsetCodeOf(s.offset)
+		offsets.encode(w, offsetCode)
+		matchLengths.encode(w, matchLengthCode)
+		literalLengths.encode(w, literalLengthCode)
+		appendExtraBits(w, s, literalLengthCode, matchLengthCode, offsetCode)
+	}
+	matchLengths.flush(w)
+	offsets.flush(w)
+	literalLengths.flush(w)
+	return w.close()
+}
+
+func appendExtraBits(w *bitWriter, s sequence, literalLengthCode, matchLengthCode, offsetCode uint8) {
+	w.addBits(uint64(s.literalLength)-uint64(literalLengthBaselines[literalLengthCode]), uint(literalLengthBits[literalLengthCode]))
+	w.addBits(uint64(s.matchLength)-uint64(matchLengthBaselines[matchLengthCode]), uint(matchLengthBits[matchLengthCode]))
+	// offsets are never sent as repeats, the offset value is the offset plus 3
+	w.addBits(uint64(s.offset+3), uint(offsetCode))
+}
+
+func literalLengthCodeOf(length int) uint8 {
+	code := maxLiteralLengthCode
+	for int(literalLengthBaselines[code]) > length {
+		code--
+	}
+	return uint8(code)
+}
+
+func matchLengthCodeOf(length int) uint8 {
+	code := maxMatchLengthCode
+	for int(matchLengthBaselines[code]) > length {
+		code--
+	}
+	return uint8(code)
+}
+
+func offsetCodeOf(offset int) uint8 {
+	return uint8(bits.Len(uint(offset+3)) - 1)
+}
@@ -0,0 +1,96 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 computes XXH64 with a zero seed, frames carry the low 32 bits of it as their content checksum.
+type xxhash64 struct {
+	v      [4]uint64
+	total  uint64
+	buffer [32]byte
+	n      int
+}
+
+func (h *xxhash64) reset() {
+	prime1, prime2 := xxhPrime1, xxhPrime2
+	h.v = [4]uint64{prime1 + prime2, prime2, 0, -prime1}
+	h.total = 0
+	h.n = 0
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	return bits.RotateLeft64(acc+input*xxhPrime2, 31) * xxhPrime1
+}
+
+func xxhMerge(acc, value uint64) uint64 {
+	return (acc^xxhRound(0, value))*xxhPrime1 + xxhPrime4
+}
+
+func (h *xxhash64) write(p []byte) {
+	h.total += uint64(len(p))
+	if h.n > 0 {
+		n := copy(h.buffer[h.n:], p)
+		h.n += n
+		p = p[n:]
+		if h.n < len(h.buffer) {
+			return
+		}
+		h.stripes(h.buffer[:])
+		h.n = 0
+	}
+	full := len(p) &^ 31
+	h.stripes(p[:full])
+	h.n = copy(h.buffer[:], p[full:])
+}
+
+func (h *xxhash64) stripes(p []byte) {
+	for ; len(p) >= 32; p = p[32:] {
+		for i := range h.v {
+			h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+		}
+	}
+}
+
+func (h *xxhash64) sum() uint64 {
+	var sum uint64
+	if h.total >= 32 {
+		sum = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			sum = xxhMerge(sum, v)
+		}
+	} else {
+		sum = h.v[2] + xxhPrime5
+	}
+	sum += h.total
+	p := h.buffer[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		sum ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		sum = bits.RotateLeft64(sum, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		sum ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		sum = bits.RotateLeft64(sum, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		sum ^= uint64(b) * xxhPrime5
+		sum = bits.RotateLeft64(sum, 11) * xxhPrime1
+	}
+	sum ^= sum >> 33
+	sum *= xxhPrime2
+	sum ^= sum >> 29
+	sum *= xxhPrime3
+	sum ^= sum >> 32
+	return sum
+}
@@ -0,0 +1,106 @@
+// Package zstd implements the Zstandard compressed data format (RFC 8878) without third party dependencies.
+//
+// Reader decodes any frame without a dictionary. Writer produces frames with greedy LZ77 matching, raw literals
+// and the predefined sequence codes, trading some compression ratio for a small and fast implementation.
+package zstd
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	frameMagic           = 0xFD2FB528
+	skippableMagicMask   = 0xFFFFFFF0
+	skippableFrameMagic  = 0x184D2A50
+	maxBlockSize         = 128 << 10
+	blockTypeRaw         = 0
+	blockTypeRLE         = 1
+	blockTypeCompressed  = 2
+	literalsTypeRaw      = 0
+	literalsTypeRLE      = 1
+	literalsTypeHuffman  = 2
+	literalsTypeTreeless = 3
+
+	// MaxWindowSize is the largest window a frame may ask the Reader to keep, frames with larger windows are
+	// rejected rather than allowed to use that much memory.
+	MaxWindowSize = 1 << 27
+)
+
+var (
+	ErrCorrupted         = errors.New("zstd: corrupted input")
+	ErrDictionary        = errors.New("zstd: dictionaries are not supported")
+	ErrWindowTooLarge    = errors.New("zstd: window size exceeds MaxWindowSize")
+	ErrChecksumMismatch  = errors.New("zstd: checksum mismatch")
+	ErrWriterClosed      = errors.New("zstd: write to a closed writer")
+	ErrInvalidFrameMagic = errors.New("zstd: invalid frame magic")
+)
+
+func corrupted(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrCorrupted, fmt.Sprintf(format, args...))
+}
+
+// baseline and number of extra bits of each literal length code
+var (
+	literalLengthBaselines = [36]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	literalLengthBits = [36]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+)
+
+// baseline and number of extra bits of each match length code
+var (
+	matchLengthBaselines = [53]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	matchLengthBits = [53]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+const (
+	maxLiteralLengthCode = 35
+	maxMatchLengthCode   = 52
+	maxOffsetCode        = 31
+
+	maxLiteralLengthLog = 9
+	maxMatchLengthLog   = 9
+	maxOffsetLog        = 8
+)
+
+// default distributions of the predefined sequence codes, -1 stands for a probability below 1
+var (
+	predefinedLiteralLengths = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	predefinedMatchLengths = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	predefinedOffsets = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+const (
+	predefinedLiteralLengthLog = 6
+	predefinedMatchLengthLog   = 6
+	predefinedOffsetLog        = 5
+)
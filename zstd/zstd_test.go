@@ -0,0 +1,188 @@
+package zstd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var sampleWords = strings.Fields("zstd frame block literals sequences offset match window huffman entropy checksum gommon stream header")
+
+// sampleText returns deterministic text, the reference frames below were produced from it by the zstd CLI.
+func sampleText(size int) []byte {
+	state := uint32(2463534242)
+	var b strings.Builder
+	for b.Len() < size {
+		state = xorshift(state)
+		b.WriteString(sampleWords[state%uint32(len(sampleWords))])
+		b.WriteByte(" \n,."[state>>8%4])
+	}
+	return []byte(b.String()[:size])
+}
+
+func sampleNoise(size int) []byte {
+	state := uint32(88675123)
+	out := make([]byte, size)
+	for i := range out {
+		state = xorshift(state)
+		out[i] = byte(state >> 24)
+	}
+	return out
+}
+
+func sampleCycle(size int) []byte {
+	out := make([]byte, size)
+	for i := range out {
+		out[i] = byte(i % 251)
+	}
+	return out
+}
+
+func xorshift(state uint32) uint32 {
+	state ^= state << 13
+	state ^= state >> 17
+	state ^= state << 5
+	return state
+}
+
+// reference frames produced by the zstd CLI, "zstd -19" for text, "zstd -1" for cycle and "zstd --no-check" for noise
+const (
+	textFrame = "KLUv/WTQBq0PABJIFxGwPWytokM73g08w5xkLR8VHc3dzN3rvb1LG3txffsyTOB9PKm9kWAptDdvK/AvQh4RVo8PCWE+2Vhn9r4f" +
+		"4A0vphQrvYAiRBrnVhIi8UYsfLFeuoduRARqX4FHAoDhqGFfTqFeHSBEYAxipx4RIBSQO220aZYxwDkGyGyEs19rvy0v96vC0xkZ" +
+		"mCJf8+87acmle/bSahdVohCr+VKiedampDz2dxi+n0EkCLLMZ4XdW6cj7xrCgaoKiVIQ0cHkwBZVDV8bGE5sHMuHjtrXsVpkAShQ" +
+		"5FzHIyjlrycaCR8xivowk3TNVz6q/jrT9SzUDpMFZwpd3IrMXL77dOIjHNE3l7elhLMRWtoajcu7MyaMj3uZBFMEwwUmv4uERZ8W" +
+		"PAQDi8Q8CMvRlRkNxENqEoounVEUiyImiTVwvP8CIpi0p0KopUFYGwHmw/IIEYcUqo8fTY4nDdi4h+C4Nq4dSnrd2u4zZaauwHmD" +
+		"TDOPvJc9y/72i7T3LeRUllUHoF0qCrCTeDUGYfLuZrdIKV2TEBvCAXhIeMBwYpSR6OMXhoYZjv5clWZTQCxV0djAUixemwNIXhik" +
+		"k4wFuqsH5cofMr/LdS3W7+p6YjORk6rEMMn1xJrhLa+WBPQTiDJ8Pla+T6wQJNLkyQsf6OpgvXBMizVUAetf5Ko="
+	cycleFrame = "KLUv/aTgkwQALAgAtA8AAQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHyAhIiMkJSYnKCkqKywtLi8wMTIzNDU2Nzg5Ojs8" +
+		"PT4/QEFCQ0RFRkdISUpLTE1OT1BRUlNUVVZXWFlaW1xdXl9gYWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXp7fH1+f4CBgoOEhYaH" +
+		"iImKi4yNjo+QkZKTlJWWl5iZmpucnZ6foKGio6SlpqeoqaqrrK2ur7CxsrO0tba3uLm6u7y9vr/AwcLDxMXGx8jJysvMzc7P0NHS" +
+		"09TV1tfY2drb3N3e3+Dh4uPk5ebn6Onq6+zt7u/w8fLz9PX29/j5+gEAe4F/f26kTAAACDIBAPz/ORACTQAACGQBANwTHQgBu55P" +
+		"Og=="
+	noiseFrame = "KLUv/SBkIQMAwiRFSYkZpqCTUELy0pwGcxDFyN7K02mRp/FxYTEL3RyLw86APCbJ4XMqQA1bPH3r3i/0Uqniq7GR5t/bz4fb9jwR" +
+		"QuppH/D2kYIF686TXihxpQnJU3Hv1mzY2n5NEyXyf7Y+fQ=="
+)
+
+func decodeFrame(t *testing.T, frame string) []byte {
+	data, err := base64.StdEncoding.DecodeString(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReaderDecodesReferenceFrames(t *testing.T) {
+	skippable := binary.LittleEndian.AppendUint32(nil, skippableFrameMagic|3)
+	skippable = append(binary.LittleEndian.AppendUint32(skippable, 3), "abc"...)
+	for _, tc := range []struct {
+		name   string
+		frames []byte
+		want   []byte
+	}{
+		{"huffman literals and checksum", decodeFrame(t, textFrame), sampleText(2000)},
+		{"multiple blocks", decodeFrame(t, cycleFrame), sampleCycle(300000)},
+		{"raw block without checksum", decodeFrame(t, noiseFrame), sampleNoise(100)},
+		{
+			"concatenated and skippable frames",
+			bytes.Join([][]byte{skippable, decodeFrame(t, noiseFrame), decodeFrame(t, textFrame)}, nil),
+			append(sampleNoise(100), sampleText(2000)...),
+		},
+	} {
+		got, err := io.ReadAll(NewReader(bytes.NewReader(tc.frames)))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: decoded %d bytes that differ from the %d expected", tc.name, len(got), len(tc.want))
+		}
+	}
+}
+
+func TestReaderRejectsInvalidFrames(t *testing.T) {
+	frame := decodeFrame(t, textFrame)
+	badChecksum := append([]byte(nil), frame...)
+	badChecksum[len(badChecksum)-1] ^= 0xff
+	for _, tc := range []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{"checksum", badChecksum, ErrChecksumMismatch},
+		{"truncated", frame[:len(frame)-10], io.ErrUnexpectedEOF},
+		{"magic", []byte("not a zstd frame"), ErrInvalidFrameMagic},
+		{"dictionary", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x01, 0x50, 0x07}, ErrDictionary},
+		{"window", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0xf8}, ErrWindowTooLarge},
+	} {
+		if _, err := io.ReadAll(NewReader(bytes.NewReader(tc.input))); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input []byte
+	}{
+		{"empty", nil},
+		{"single byte", []byte("z")},
+		{"run", bytes.Repeat([]byte{'z'}, 300000)},
+		{"text", sampleText(1 << 20)},
+		{"noise", sampleNoise(200000)},
+		{"cycle beyond the window", sampleCycle(3 << 20)},
+	} {
+		var compressed bytes.Buffer
+		w := NewWriter(&compressed)
+		// uneven writes cross block boundaries
+		for p := tc.input; len(p) > 0; {
+			n := min(len(p), 70001)
+			if _, err := w.Write(p[:n]); err != nil {
+				t.Fatalf("%s: unexpected write error %v", tc.name, err)
+			}
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: unexpected close error %v", tc.name, err)
+		}
+		if _, err := w.Write([]byte("late")); err != ErrWriterClosed {
+			t.Errorf("%s: expected ErrWriterClosed, got %v", tc.name, err)
+		}
+		if tc.name != "noise" && len(tc.input) > 1000 && compressed.Len() > len(tc.input)/2 {
+			t.Errorf("%s: compressed %d bytes to %d", tc.name, len(tc.input), compressed.Len())
+		}
+		got, err := io.ReadAll(NewReader(&compressed))
+		if err != nil {
+			t.Fatalf("%s: unexpected read error %v", tc.name, err)
+		}
+		if !bytes.Equal(got, tc.input) {
+			t.Errorf("%s: round trip of %d bytes returned %d different bytes", tc.name, len(tc.input), len(got))
+		}
+	}
+}
+
+func TestXXHash64(t *testing.T) {
+	var h xxhash64
+	for _, tc := range []struct {
+		input string
+		want  uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	} {
+		h.reset()
+		// split writes exercise the buffered stripe
+		for _, part := range strings.SplitAfter(tc.input, "s") {
+			h.write([]byte(part))
+		}
+		if got := h.sum(); got != tc.want {
+			t.Errorf("xxh64(%q): expected %x, got %x", tc.input, tc.want, got)
+		}
+	}
+}